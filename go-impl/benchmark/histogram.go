@@ -0,0 +1,119 @@
+package benchmark
+
+import (
+	"math"
+
+	bpb "grpc-over-http3/proto/benchmark"
+)
+
+// Histogram is a compressed log-linear latency histogram in the same style
+// as the grpc.testing benchmark's HistogramData: buckets grow geometrically
+// by `resolution`, which keeps the structure small regardless of how many
+// samples are recorded while still giving usable percentiles.
+type Histogram struct {
+	resolution  float64
+	maxPossible float64
+	multiplier  float64
+	buckets     []uint64
+
+	min   float64
+	max   float64
+	sum   float64
+	sumSq float64
+	count float64
+}
+
+// NewHistogram creates a histogram covering [0, maxPossible) with buckets
+// whose width grows by `resolution` (e.g. 0.01 for 1% buckets).
+func NewHistogram(resolution, maxPossible float64) *Histogram {
+	multiplier := 1.0 + resolution
+	numBuckets := int(math.Log(maxPossible)/math.Log(multiplier)) + 1
+	return &Histogram{
+		resolution:  resolution,
+		maxPossible: maxPossible,
+		multiplier:  multiplier,
+		buckets:     make([]uint64, numBuckets),
+		min:         maxPossible,
+		max:         0,
+	}
+}
+
+func (h *Histogram) bucketFor(value float64) int {
+	if value < 1 {
+		return 0
+	}
+	idx := int(math.Log(value) / math.Log(h.multiplier))
+	if idx >= len(h.buckets) {
+		idx = len(h.buckets) - 1
+	}
+	return idx
+}
+
+// Record adds a single observation (in whatever unit the histogram was
+// created for, typically nanoseconds).
+func (h *Histogram) Record(value float64) {
+	h.buckets[h.bucketFor(value)]++
+	h.sum += value
+	h.sumSq += value * value
+	h.count++
+	if value < h.min {
+		h.min = value
+	}
+	if value > h.max {
+		h.max = value
+	}
+}
+
+// Merge folds another histogram with identical bucket layout into this one.
+func (h *Histogram) Merge(other *Histogram) {
+	for i, c := range other.buckets {
+		h.buckets[i] += c
+	}
+	h.sum += other.sum
+	h.sumSq += other.sumSq
+	h.count += other.count
+	if other.min < h.min {
+		h.min = other.min
+	}
+	if other.max > h.max {
+		h.max = other.max
+	}
+}
+
+// ValueAtPercentile returns the approximate value (bucket midpoint) at the
+// given percentile (0-100).
+func (h *Histogram) ValueAtPercentile(p float64) float64 {
+	if h.count == 0 {
+		return 0
+	}
+	target := h.count * (p / 100)
+	var accum float64
+	for i, c := range h.buckets {
+		accum += float64(c)
+		if accum >= target {
+			lower := math.Pow(h.multiplier, float64(i))
+			upper := math.Pow(h.multiplier, float64(i+1))
+			return (lower + upper) / 2
+		}
+	}
+	return h.max
+}
+
+// ToProto converts the histogram into the wire-shaped HistogramData used by
+// ClientStats.
+func (h *Histogram) ToProto() *HistogramData {
+	return &HistogramData{
+		Bucket:       append([]uint64(nil), h.buckets...),
+		MinSeen:      h.min,
+		MaxSeen:      h.max,
+		Sum:          h.sum,
+		SumOfSquares: h.sumSq,
+		Count:        h.count,
+		Resolution:   h.resolution,
+		MaxPossible:  h.maxPossible,
+	}
+}
+
+// HistogramData is an alias onto the HistogramData message generated from
+// proto/benchmark.proto, matching the proto-generated aliases in types.go.
+type HistogramData = bpb.HistogramData
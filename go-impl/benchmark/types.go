@@ -0,0 +1,37 @@
+package benchmark
+
+import bpb "grpc-over-http3/proto/benchmark"
+
+// Message and control-plane types for the gRPC benchmark harness. These are
+// aliases onto the stubs generated from proto/benchmark.proto, so the rest
+// of this package (and its callers) can refer to them by their unqualified
+// proto message names instead of repeating the bpb prefix everywhere - the
+// same convention server/echo_server.go follows for pb.EchoRequest et al.
+type (
+	PayloadType  = bpb.PayloadType
+	ResponseType = bpb.ResponseType
+	RpcType      = bpb.RpcType
+	LoadType     = bpb.LoadType
+
+	Payload        = bpb.Payload
+	SimpleRequest  = bpb.SimpleRequest
+	SimpleResponse = bpb.SimpleResponse
+	LoadParams     = bpb.LoadParams
+	ServerArgs     = bpb.ServerArgs
+	ServerStatus   = bpb.ServerStatus
+	ClientArgs     = bpb.ClientArgs
+	ClientStats    = bpb.ClientStats
+	ClientStatus   = bpb.ClientStatus
+)
+
+const (
+	PayloadCompressable = bpb.PayloadType_COMPRESSABLE
+
+	ResponsePlain = bpb.ResponseType_PLAIN
+
+	RpcUnary     = bpb.RpcType_UNARY
+	RpcStreaming = bpb.RpcType_STREAMING
+
+	LoadClosedLoop = bpb.LoadType_CLOSED_LOOP
+	LoadPoisson    = bpb.LoadType_POISSON
+)
@@ -0,0 +1,218 @@
+package benchmark
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"runtime"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+
+	bpb "grpc-over-http3/proto/benchmark"
+)
+
+// BenchmarkServer implements BenchmarkService, the data-plane RPCs a
+// WorkerService run actually measures: a unary call plus the three
+// streaming shapes used to characterize HTTP/2 vs HTTP/3 multiplexing.
+type BenchmarkServer struct {
+	bpb.UnimplementedBenchmarkServiceServer
+
+	Protocol string // "HTTP/2" or "HTTP/3", stamped onto responses for logging
+}
+
+func (s *BenchmarkServer) UnaryCall(ctx context.Context, req *SimpleRequest) (*SimpleResponse, error) {
+	return &SimpleResponse{Payload: makePayload(req.ResponseSize)}, nil
+}
+
+// StreamingCall echoes one response per request received, in order, until
+// the client closes the stream.
+func (s *BenchmarkServer) StreamingCall(stream bpb.BenchmarkService_StreamingCallServer) error {
+	for {
+		req, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(&SimpleResponse{Payload: makePayload(req.ResponseSize)}); err != nil {
+			return err
+		}
+	}
+}
+
+// StreamingFromClient consumes the whole request stream and returns a single
+// summary response once the client half-closes.
+func (s *BenchmarkServer) StreamingFromClient(stream bpb.BenchmarkService_StreamingFromClientServer) error {
+	var last *SimpleRequest
+	for {
+		req, err := stream.Recv()
+		if err != nil {
+			break
+		}
+		last = req
+	}
+	if last == nil {
+		return stream.SendAndClose(&SimpleResponse{})
+	}
+	return stream.SendAndClose(&SimpleResponse{Payload: makePayload(last.ResponseSize)})
+}
+
+// StreamingFromServer sends a single request's worth of responses until the
+// context is cancelled, simulating an unbounded server-push stream.
+func (s *BenchmarkServer) StreamingFromServer(req *SimpleRequest, stream bpb.BenchmarkService_StreamingFromServerServer) error {
+	for {
+		select {
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		default:
+			if err := stream.Send(&SimpleResponse{Payload: makePayload(req.ResponseSize)}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func makePayload(size int32) *Payload {
+	if size <= 0 {
+		return &Payload{Type: PayloadCompressable}
+	}
+	return &Payload{Type: PayloadCompressable, Body: make([]byte, size)}
+}
+
+// WorkerServer implements WorkerService, the control-plane channel a driver
+// dials to configure and run a benchmark load generator against
+// BenchmarkServer without shelling out to a separate script.
+type WorkerServer struct {
+	bpb.UnimplementedWorkerServiceServer
+
+	mu      sync.Mutex
+	running bool
+}
+
+// RunServer is unused by latency_benchmark.go today - BenchmarkServer is
+// always started in-process by server/server.go rather than spawned on
+// demand - so this just echoes back a ServerStatus for every ServerArgs
+// received, satisfying the generated interface without taking on a
+// process-spawning responsibility here.
+func (w *WorkerServer) RunServer(stream bpb.WorkerService_RunServerServer) error {
+	for {
+		args, err := stream.Recv()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if err := stream.Send(&ServerStatus{Port: args.Port, Cores: int32(runtime.NumCPU())}); err != nil {
+			return err
+		}
+	}
+}
+
+// RunClient runs one Mark per ClientArgs received on the stream, sending
+// back the resulting ClientStats as a ClientStatus before waiting for the
+// next one.
+func (w *WorkerServer) RunClient(stream bpb.WorkerService_RunClientServer) error {
+	for {
+		args, err := stream.Recv()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		stats, err := w.Mark(stream.Context(), args)
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(&ClientStatus{Stats: stats}); err != nil {
+			return err
+		}
+	}
+}
+
+// Mark runs a single ClientArgs configuration to completion (or until the
+// configured duration elapses) and returns the resulting ClientStats, which
+// the caller can translate directly into a LatencyResult.
+func (w *WorkerServer) Mark(ctx context.Context, args *ClientArgs) (*ClientStats, error) {
+	w.mu.Lock()
+	if w.running {
+		w.mu.Unlock()
+		return nil, fmt.Errorf("worker is already running a benchmark")
+	}
+	w.running = true
+	w.mu.Unlock()
+	defer func() {
+		w.mu.Lock()
+		w.running = false
+		w.mu.Unlock()
+	}()
+
+	conn, err := grpc.DialContext(ctx, args.ServerTarget, grpc.WithInsecure())
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial %s: %v", args.ServerTarget, err)
+	}
+	defer conn.Close()
+
+	hist := NewHistogram(0.01, float64((60 * time.Second).Nanoseconds()))
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var sent, failed int64
+
+	deadline := time.Now().Add(time.Duration(args.DurationSecs) * time.Second)
+	start := time.Now()
+
+	for c := int32(0); c < args.Channels; c++ {
+		for o := int32(0); o < args.OutstandingRpcsPerChannel; o++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for time.Now().Before(deadline) {
+					if args.LoadParams.LoadType == LoadPoisson && args.LoadParams.PoissonRate > 0 {
+						interval := rand.ExpFloat64() / args.LoadParams.PoissonRate
+						time.Sleep(time.Duration(interval * float64(time.Second)))
+					}
+
+					reqStart := time.Now()
+					err := doUnaryCall(ctx, conn, args.RequestPayloadSize, args.ResponsePayloadSize)
+					latency := time.Since(reqStart)
+
+					mu.Lock()
+					if err != nil {
+						failed++
+					} else {
+						sent++
+						hist.Record(float64(latency.Nanoseconds()))
+					}
+					mu.Unlock()
+				}
+			}()
+		}
+	}
+	wg.Wait()
+
+	return &ClientStats{
+		Latencies:      hist.ToProto(),
+		TimeElapsed:    time.Since(start).Seconds(),
+		RequestsSent:   sent,
+		RequestsFailed: failed,
+	}, nil
+}
+
+// doUnaryCall issues one real BenchmarkService.UnaryCall over conn so Mark's
+// latency histogram and success/failure counts reflect the target's actual
+// behavior.
+func doUnaryCall(ctx context.Context, conn *grpc.ClientConn, reqSize, respSize int32) error {
+	if reqSize < 0 {
+		reqSize = 0
+	}
+	client := bpb.NewBenchmarkServiceClient(conn)
+	_, err := client.UnaryCall(ctx, &SimpleRequest{
+		ResponseSize: respSize,
+		Payload:      makePayload(reqSize),
+	})
+	return err
+}
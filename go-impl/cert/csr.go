@@ -0,0 +1,51 @@
+// Package cert builds out the certificate lifecycle the self-signed
+// CertManager in server/cert_manager.go doesn't cover: CSR generation, CA
+// signing for multi-host test topologies, and hot-reloading a running
+// server's TLS config when the cert on disk changes or is close to expiry.
+package cert
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"net"
+)
+
+// GenerateCSR creates a new RSA key pair and a PKCS#10 certificate signing
+// request for the given subject and subject alternative names (hostnames or
+// IPs), returning the CSR and the private key, both PEM-encoded.
+func GenerateCSR(subject pkix.Name, sans []string) (csrPEM, keyPEM []byte, err error) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate private key: %v", err)
+	}
+
+	template := x509.CertificateRequest{
+		Subject: subject,
+	}
+	for _, san := range sans {
+		if ip := net.ParseIP(san); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, san)
+		}
+	}
+
+	csrDER, err := x509.CreateCertificateRequest(rand.Reader, &template, privateKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create CSR: %v", err)
+	}
+
+	csrPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csrDER})
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal private key: %v", err)
+	}
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+
+	return csrPEM, keyPEM, nil
+}
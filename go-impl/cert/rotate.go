@@ -0,0 +1,127 @@
+package cert
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"time"
+)
+
+func parseLeaf(der []byte) (*x509.Certificate, error) {
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate: %v", err)
+	}
+	return leaf, nil
+}
+
+// AutoRotator regenerates a self-signed cert in place when it is within
+// expiryThreshold of its NotAfter, so long-running latency campaigns don't
+// silently lose their HTTP/3 numbers to an expired cert mid-run.
+type AutoRotator struct {
+	CertPath        string
+	KeyPath         string
+	SANs            []string
+	CommonName      string
+	ValidFor        time.Duration
+	ExpiryThreshold time.Duration
+}
+
+// CheckAndRotate regenerates the self-signed certificate if it's within
+// ExpiryThreshold of expiring (or missing entirely), and reports whether it
+// did so.
+func (r *AutoRotator) CheckAndRotate() (rotated bool, err error) {
+	needsRotation, err := r.needsRotation()
+	if err != nil {
+		return false, err
+	}
+	if !needsRotation {
+		return false, nil
+	}
+
+	if err := r.generate(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *AutoRotator) needsRotation() (bool, error) {
+	data, err := os.ReadFile(r.CertPath)
+	if os.IsNotExist(err) {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s: %v", r.CertPath, err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return true, nil
+	}
+
+	leaf, err := parseLeaf(block.Bytes)
+	if err != nil {
+		return true, nil
+	}
+
+	return time.Until(leaf.NotAfter) < r.ExpiryThreshold, nil
+}
+
+func (r *AutoRotator) generate() error {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return fmt.Errorf("failed to generate private key: %v", err)
+	}
+
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{Country: []string{"JP"}, Organization: []string{"GRPC-Benchmark"}, CommonName: r.CommonName},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(r.ValidFor),
+		KeyUsage:     x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, san := range r.SANs {
+		if ip := net.ParseIP(san); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, san)
+		}
+	}
+
+	certDER, err := x509.CreateCertificate(rand.Reader, &template, &template, &privateKey.PublicKey, privateKey)
+	if err != nil {
+		return fmt.Errorf("failed to create certificate: %v", err)
+	}
+
+	certOut, err := os.Create(r.CertPath)
+	if err != nil {
+		return fmt.Errorf("failed to open cert file for writing: %v", err)
+	}
+	defer certOut.Close()
+	if err := pem.Encode(certOut, &pem.Block{Type: "CERTIFICATE", Bytes: certDER}); err != nil {
+		return fmt.Errorf("failed to write cert data: %v", err)
+	}
+
+	keyOut, err := os.Create(r.KeyPath)
+	if err != nil {
+		return fmt.Errorf("failed to open key file for writing: %v", err)
+	}
+	defer keyOut.Close()
+
+	privBytes, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return fmt.Errorf("failed to marshal private key: %v", err)
+	}
+	if err := pem.Encode(keyOut, &pem.Block{Type: "PRIVATE KEY", Bytes: privBytes}); err != nil {
+		return fmt.Errorf("failed to write key data: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,131 @@
+package cert
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Watcher fsnotify-watches a cert/key pair on disk and atomically swaps the
+// certificate a running *tls.Config serves, via GetCertificate, so HTTP/2
+// and HTTP/3 servers sharing the same tls.Config pick up renewals without a
+// restart.
+type Watcher struct {
+	CertPath string
+	KeyPath  string
+
+	mu   sync.RWMutex
+	cert *tls.Certificate
+
+	watcher *fsnotify.Watcher
+	done    chan struct{}
+}
+
+// NewWatcher loads the initial cert/key pair and starts watching both files
+// for changes.
+func NewWatcher(certPath, keyPath string) (*Watcher, error) {
+	w := &Watcher{CertPath: certPath, KeyPath: keyPath, done: make(chan struct{})}
+
+	if err := w.reload(); err != nil {
+		return nil, err
+	}
+
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create fsnotify watcher: %v", err)
+	}
+	if err := fsw.Add(certPath); err != nil {
+		return nil, fmt.Errorf("failed to watch %s: %v", certPath, err)
+	}
+	if err := fsw.Add(keyPath); err != nil {
+		return nil, fmt.Errorf("failed to watch %s: %v", keyPath, err)
+	}
+	w.watcher = fsw
+
+	go w.run()
+
+	return w, nil
+}
+
+func (w *Watcher) run() {
+	for {
+		select {
+		case <-w.done:
+			return
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+				continue
+			}
+			if err := w.reload(); err != nil {
+				log.Printf("cert watcher: failed to reload %s/%s: %v", w.CertPath, w.KeyPath, err)
+			} else {
+				log.Printf("cert watcher: reloaded certificate from %s", w.CertPath)
+			}
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("cert watcher: fsnotify error: %v", err)
+		}
+	}
+}
+
+func (w *Watcher) reload() error {
+	cert, err := tls.LoadX509KeyPair(w.CertPath, w.KeyPath)
+	if err != nil {
+		return fmt.Errorf("failed to load key pair: %v", err)
+	}
+
+	w.mu.Lock()
+	w.cert = &cert
+	w.mu.Unlock()
+
+	return nil
+}
+
+// GetCertificate satisfies tls.Config.GetCertificate, always returning the
+// most recently loaded certificate.
+func (w *Watcher) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.cert, nil
+}
+
+// Close stops the underlying fsnotify watcher.
+func (w *Watcher) Close() error {
+	close(w.done)
+	if w.watcher != nil {
+		return w.watcher.Close()
+	}
+	return nil
+}
+
+// ExpiresWithin reports whether the currently loaded certificate expires
+// within the given duration, for driving auto-rotation.
+func (w *Watcher) ExpiresWithin(d time.Duration) (bool, error) {
+	w.mu.RLock()
+	cert := w.cert
+	w.mu.RUnlock()
+
+	if cert == nil || len(cert.Certificate) == 0 {
+		return false, fmt.Errorf("no certificate loaded")
+	}
+
+	leaf := cert.Leaf
+	if leaf == nil {
+		parsed, err := parseLeaf(cert.Certificate[0])
+		if err != nil {
+			return false, err
+		}
+		leaf = parsed
+	}
+
+	return time.Until(leaf.NotAfter) < d, nil
+}
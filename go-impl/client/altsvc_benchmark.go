@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"grpc-over-http3/common"
+	"grpc-over-http3/hdr"
+
+	"github.com/quic-go/quic-go/http3"
+)
+
+// altSvcProbePath is the endpoint requested on every iteration:
+// server.go's altSvcMiddleware wraps the whole HTTP/2 handler, so any path
+// carries the Alt-Svc header, but /health is the cheapest one to read.
+const altSvcProbePath = "/health"
+
+// runAltSvcBenchmark exercises the same Alt-Svc upgrade path as
+// test_http_client.go's testAltSvcUpgrade, but config.Requests times over a
+// single shared common.AltSvcCache, so the ratio of cold HTTP/2 requests to
+// upgraded HTTP/3 ones - and their respective latencies - can be measured
+// at benchmark scale instead of a one-off connectivity check. Requests run
+// sequentially against one origin: the point is to measure the upgrade
+// itself, not to load-test it, so there's no connections/goroutine fan-out
+// here the way runHTTP2Benchmark/runHTTP3Benchmark have.
+func runAltSvcBenchmark(config BenchmarkConfig) BenchmarkResult {
+	logger := common.NewLogger("INFO")
+	logger.Info("================================================")
+	logger.Info("Starting Alt-Svc upgrade benchmark", "requests", config.Requests)
+	logger.Info("================================================")
+
+	origin := common.Origin(config.ServerAddr, 443)
+	cache := common.NewAltSvcCache()
+
+	http2Client := &http.Client{Timeout: 10 * time.Second}
+	http3Client := &http.Client{
+		Transport: &http3.RoundTripper{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+		Timeout: 10 * time.Second,
+	}
+
+	var successfulReqs, failedReqs, upgradedReqs int
+	hist := hdr.New(histogramLowest, histogramHighest, histogramSigFigs)
+	upgradeHist := hdr.New(histogramLowest, histogramHighest, histogramSigFigs)
+
+	start := time.Now()
+	for i := 0; i < config.Requests; i++ {
+		h3Port, upgraded := cache.Lookup(origin)
+		client, url := http2Client, fmt.Sprintf("http://%s:443%s", config.ServerAddr, altSvcProbePath)
+		if upgraded {
+			client, url = http3Client, fmt.Sprintf("https://%s:%d%s", config.ServerAddr, h3Port, altSvcProbePath)
+		}
+
+		reqStart := time.Now()
+		err := doAltSvcProbe(context.Background(), client, url, origin, cache)
+		latency := time.Since(reqStart)
+
+		if err != nil {
+			failedReqs++
+			logger.Debug("Alt-Svc probe failed", "request", i, "error", err)
+			continue
+		}
+
+		successfulReqs++
+		hist.RecordDuration(latency)
+		if upgraded {
+			upgradedReqs++
+			upgradeHist.RecordDuration(latency)
+		}
+	}
+	totalTime := time.Since(start)
+
+	result := BenchmarkResult{
+		TotalRequests:    config.Requests,
+		SuccessfulReqs:   successfulReqs,
+		FailedReqs:       failedReqs,
+		TotalTime:        totalTime,
+		Throughput:       float64(successfulReqs) / totalTime.Seconds(),
+		ErrorRate:        float64(failedReqs) / float64(config.Requests),
+		Protocol:         "HTTP/2+3 (Alt-Svc)",
+		Mode:             AltSvcUpgradeMode,
+		Histogram:        hist,
+		UpgradedRequests: upgradedReqs,
+		AvgLatency:       time.Duration(hist.Mean()),
+		MinLatency:       time.Duration(hist.Min()),
+		MaxLatency:       time.Duration(hist.Max()),
+		StdDevLatency:    time.Duration(hist.StdDev()),
+		P50Latency:       time.Duration(hist.ValueAtPercentile(50)),
+		P90Latency:       time.Duration(hist.ValueAtPercentile(90)),
+		P95Latency:       time.Duration(hist.ValueAtPercentile(95)),
+		P99Latency:       time.Duration(hist.ValueAtPercentile(99)),
+		P999Latency:      time.Duration(hist.ValueAtPercentile(99.9)),
+		P9999Latency:     time.Duration(hist.ValueAtPercentile(99.99)),
+	}
+	if upgradedReqs > 0 {
+		result.AvgUpgradeLatency = time.Duration(upgradeHist.Mean())
+	}
+
+	logger.Info("Alt-Svc upgrade benchmark completed",
+		"successful", successfulReqs,
+		"failed", failedReqs,
+		"upgraded", upgradedReqs,
+		"avg_upgrade_latency", result.AvgUpgradeLatency)
+
+	return result
+}
+
+// doAltSvcProbe issues one GET to url and, if the response carries an
+// Alt-Svc header, records it in cache under origin so a later iteration of
+// runAltSvcBenchmark's loop can upgrade to HTTP/3.
+func doAltSvcProbe(ctx context.Context, client *http.Client, url, origin string, cache *common.AltSvcCache) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to build request: %v", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if altSvcHeader := resp.Header.Get("Alt-Svc"); altSvcHeader != "" {
+		cache.Observe(origin, altSvcHeader)
+	}
+
+	if _, err := io.ReadAll(resp.Body); err != nil {
+		return fmt.Errorf("failed to read response body: %v", err)
+	}
+	return nil
+}
@@ -2,11 +2,15 @@ package main
 
 import (
 	"encoding/csv"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
+
+	"grpc-over-http3/hdr"
 )
 
 func generateCSVReport(results []BenchmarkResult, filename string) error {
@@ -22,8 +26,13 @@ func generateCSVReport(results []BenchmarkResult, filename string) error {
 	// ヘッダー
 	header := []string{
 		"Protocol", "Delay(ms)", "Loss(%)", "TotalRequests", "SuccessfulReqs",
-		"FailedReqs", "TotalTime(ms)", "AvgLatency(ms)", "MinLatency(ms)",
-		"MaxLatency(ms)", "P95Latency(ms)", "P99Latency(ms)", "Throughput(rps)", "ErrorRate(%)",
+		"FailedReqs", "TotalTime(ms)", "AvgLatency(ms)", "StdDevLatency(ms)", "MinLatency(ms)",
+		"MaxLatency(ms)", "P50Latency(ms)", "P90Latency(ms)", "P95Latency(ms)", "P99Latency(ms)",
+		"P999Latency(ms)", "P9999Latency(ms)", "Throughput(rps)", "ErrorRate(%)",
+		"StreamMode", "StreamOpenLatency(ms)", "MessagesPerStream", "InFlightWindow",
+		"UpgradedRequests", "AvgUpgradeLatency(ms)",
+		"ObservedDelay(ms)", "ObservedLoss(%)",
+		"Invalid", "InvalidReason",
 	}
 	if err := writer.Write(header); err != nil {
 		return fmt.Errorf("failed to write header: %v", err)
@@ -40,12 +49,27 @@ func generateCSVReport(results []BenchmarkResult, filename string) error {
 			strconv.Itoa(result.FailedReqs),
 			strconv.FormatFloat(float64(result.TotalTime.Milliseconds()), 'f', 2, 64),
 			strconv.FormatFloat(float64(result.AvgLatency.Microseconds())/1000, 'f', 2, 64),
+			strconv.FormatFloat(float64(result.StdDevLatency.Microseconds())/1000, 'f', 2, 64),
 			strconv.FormatFloat(float64(result.MinLatency.Microseconds())/1000, 'f', 2, 64),
 			strconv.FormatFloat(float64(result.MaxLatency.Microseconds())/1000, 'f', 2, 64),
+			strconv.FormatFloat(float64(result.P50Latency.Microseconds())/1000, 'f', 2, 64),
+			strconv.FormatFloat(float64(result.P90Latency.Microseconds())/1000, 'f', 2, 64),
 			strconv.FormatFloat(float64(result.P95Latency.Microseconds())/1000, 'f', 2, 64),
 			strconv.FormatFloat(float64(result.P99Latency.Microseconds())/1000, 'f', 2, 64),
+			strconv.FormatFloat(float64(result.P999Latency.Microseconds())/1000, 'f', 2, 64),
+			strconv.FormatFloat(float64(result.P9999Latency.Microseconds())/1000, 'f', 2, 64),
 			strconv.FormatFloat(result.Throughput, 'f', 2, 64),
 			strconv.FormatFloat(result.ErrorRate*100, 'f', 2, 64),
+			result.Mode.String(),
+			strconv.FormatFloat(float64(result.StreamOpenLatency.Microseconds())/1000, 'f', 2, 64),
+			strconv.Itoa(result.MessagesPerStream),
+			strconv.Itoa(result.InFlightWindow),
+			strconv.Itoa(result.UpgradedRequests),
+			strconv.FormatFloat(float64(result.AvgUpgradeLatency.Microseconds())/1000, 'f', 2, 64),
+			strconv.Itoa(result.ObservedImpairment.DelayMs),
+			strconv.FormatFloat(result.ObservedImpairment.LossPct, 'f', 2, 64),
+			strconv.FormatBool(result.Invalid),
+			result.InvalidReason,
 		}
 		if err := writer.Write(record); err != nil {
 			return fmt.Errorf("failed to write record: %v", err)
@@ -55,6 +79,76 @@ func generateCSVReport(results []BenchmarkResult, filename string) error {
 	return nil
 }
 
+// generateJSONLReport writes one BenchmarkResult per line as JSON, relying
+// on BenchmarkResult's json tags for nanosecond-precision durations - unlike
+// the CSV/Markdown reports, which round to milliseconds for readability,
+// this format exists so downstream tooling can recompute its own aggregates
+// without having already lost precision.
+func generateJSONLReport(results []BenchmarkResult, filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create JSONL file: %v", err)
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+	for _, result := range results {
+		if err := encoder.Encode(result); err != nil {
+			return fmt.Errorf("failed to encode result: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// generatePromReport renders every result as Prometheus text exposition
+// format, hand-rolled in the same style as metrics/server.go's
+// writePrometheus rather than pulling in client_golang for a one-shot file
+// dump.
+func generatePromReport(results []BenchmarkResult, filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create Prometheus file: %v", err)
+	}
+	defer file.Close()
+
+	fmt.Fprintln(file, "# HELP grpc_bench_latency_seconds Latency percentiles observed during one benchmark run.")
+	fmt.Fprintln(file, "# TYPE grpc_bench_latency_seconds gauge")
+	for _, result := range results {
+		labels := fmt.Sprintf("protocol=%q,delay_ms=\"%d\",loss_pct=\"%d\"",
+			result.Protocol, result.TestCase.Delay, result.TestCase.Loss)
+		for _, p := range []struct {
+			quantile string
+			latency  time.Duration
+		}{
+			{"0.5", result.P50Latency},
+			{"0.9", result.P90Latency},
+			{"0.95", result.P95Latency},
+			{"0.99", result.P99Latency},
+			{"0.999", result.P999Latency},
+			{"0.9999", result.P9999Latency},
+		} {
+			fmt.Fprintf(file, "grpc_bench_latency_seconds{%s,quantile=%q} %g\n", labels, p.quantile, p.latency.Seconds())
+		}
+	}
+
+	fmt.Fprintln(file, "# HELP grpc_bench_throughput_rps Requests per second achieved during one benchmark run.")
+	fmt.Fprintln(file, "# TYPE grpc_bench_throughput_rps gauge")
+	for _, result := range results {
+		fmt.Fprintf(file, "grpc_bench_throughput_rps{protocol=%q,delay_ms=\"%d\",loss_pct=\"%d\"} %g\n",
+			result.Protocol, result.TestCase.Delay, result.TestCase.Loss, result.Throughput)
+	}
+
+	fmt.Fprintln(file, "# HELP grpc_bench_error_rate Fraction of requests that failed during one benchmark run.")
+	fmt.Fprintln(file, "# TYPE grpc_bench_error_rate gauge")
+	for _, result := range results {
+		fmt.Fprintf(file, "grpc_bench_error_rate{protocol=%q,delay_ms=\"%d\",loss_pct=\"%d\"} %g\n",
+			result.Protocol, result.TestCase.Delay, result.TestCase.Loss, result.ErrorRate)
+	}
+
+	return nil
+}
+
 func generatePerformanceReport(results []BenchmarkResult, logDir string) error {
 	timestamp := time.Now().Format("20060102_150405")
 	reportFile := filepath.Join(logDir, fmt.Sprintf("performance_report_%s.txt", timestamp))
@@ -95,53 +189,97 @@ func generatePerformanceReport(results []BenchmarkResult, logDir string) error {
 
 	// 詳細結果
 	fmt.Fprintf(file, "## Detailed Results\n\n")
-	fmt.Fprintf(file, "| Protocol | Delay(ms) | Loss(%%) | Avg Latency(ms) | P95 Latency(ms) | P99 Latency(ms) | Throughput(rps) | Error Rate(%%) |\n")
-	fmt.Fprintf(file, "|----------|-----------|---------|-----------------|-----------------|-----------------|-----------------|----------------|\n")
+	fmt.Fprintf(file, "| Protocol | Delay(ms) | Loss(%%) | Avg Latency(ms) | StdDev(ms) | P50(ms) | P99 Latency(ms) | P99.9(ms) | Throughput(rps) | Error Rate(%%) | CDF |\n")
+	fmt.Fprintf(file, "|----------|-----------|---------|-----------------|------------|---------|-----------------|-----------|-----------------|----------------|-----|\n")
 
 	for _, result := range results {
-		fmt.Fprintf(file, "| %s | %d | %d | %.2f | %.2f | %.2f | %.2f | %.2f |\n",
+		hgrmFile, err := writeHgrmFile(result, logDir)
+		if err != nil {
+			return fmt.Errorf("failed to write histogram for %s delay=%dms loss=%d%%: %v",
+				result.Protocol, result.TestCase.Delay, result.TestCase.Loss, err)
+		}
+
+		fmt.Fprintf(file, "| %s | %d | %d | %.2f | %.2f | %.2f | %.2f | %.2f | %.2f | %.2f | %s |\n",
 			result.Protocol,
 			result.TestCase.Delay,
 			result.TestCase.Loss,
 			float64(result.AvgLatency.Microseconds())/1000,
-			float64(result.P95Latency.Microseconds())/1000,
+			float64(result.StdDevLatency.Microseconds())/1000,
+			float64(result.P50Latency.Microseconds())/1000,
 			float64(result.P99Latency.Microseconds())/1000,
+			float64(result.P999Latency.Microseconds())/1000,
 			result.Throughput,
 			result.ErrorRate*100,
+			hgrmFile,
 		)
 	}
 
 	return nil
 }
 
+// writeHgrmFile renders result's full latency distribution to
+// latencies_<protocol>_<delay>_<loss>.hgrm alongside the report and returns
+// its basename (or "-" if the result has no histogram, e.g. a connection
+// that never recorded a successful request) for linking from the detailed
+// results table.
+func writeHgrmFile(result BenchmarkResult, logDir string) (string, error) {
+	if result.Histogram == nil {
+		return "-", nil
+	}
+
+	protocol := strings.ReplaceAll(strings.ToLower(result.Protocol), "/", "")
+	name := fmt.Sprintf("latencies_%s_%d_%d.hgrm", protocol, result.TestCase.Delay, result.TestCase.Loss)
+
+	file, err := os.Create(filepath.Join(logDir, name))
+	if err != nil {
+		return "", fmt.Errorf("failed to create hgrm file: %v", err)
+	}
+	defer file.Close()
+
+	// ナノ秒単位のヒストグラムをミリ秒で出力
+	if err := result.Histogram.WritePercentileDistribution(file, float64(time.Millisecond)); err != nil {
+		return "", fmt.Errorf("failed to write hgrm file: %v", err)
+	}
+
+	return name, nil
+}
+
+// filterResults keeps only results for protocol, excluding any marked
+// Invalid by a failed health check so a server that crashed mid-run can't
+// silently drag down (or, worse, look fine in) the averaged summary.
 func filterResults(results []BenchmarkResult, protocol string) []BenchmarkResult {
 	var filtered []BenchmarkResult
 	for _, result := range results {
-		if result.Protocol == protocol {
+		if result.Protocol == protocol && !result.Invalid {
 			filtered = append(filtered, result)
 		}
 	}
 	return filtered
 }
 
+// calculateAverage summarizes results (one per test case, same protocol).
+// Throughput and error rate are simple means; latency percentiles are
+// instead read off a histogram merged from every test case's Histogram,
+// since averaging percentiles computed over different network conditions
+// doesn't produce a meaningful percentile of anything.
 func calculateAverage(results []BenchmarkResult) BenchmarkResult {
 	if len(results) == 0 {
 		return BenchmarkResult{}
 	}
 
-	var totalAvgLatency, totalP95Latency, totalP99Latency time.Duration
 	var totalThroughput, totalErrorRate float64
 	var totalRequests, totalSuccessfulReqs, totalFailedReqs int
+	merged := hdr.New(histogramLowest, histogramHighest, histogramSigFigs)
 
 	for _, result := range results {
-		totalAvgLatency += result.AvgLatency
-		totalP95Latency += result.P95Latency
-		totalP99Latency += result.P99Latency
 		totalThroughput += result.Throughput
 		totalErrorRate += result.ErrorRate
 		totalRequests += result.TotalRequests
 		totalSuccessfulReqs += result.SuccessfulReqs
 		totalFailedReqs += result.FailedReqs
+		if result.Histogram != nil {
+			merged.Merge(result.Histogram)
+		}
 	}
 
 	count := len(results)
@@ -149,10 +287,18 @@ func calculateAverage(results []BenchmarkResult) BenchmarkResult {
 		TotalRequests:  totalRequests,
 		SuccessfulReqs: totalSuccessfulReqs,
 		FailedReqs:     totalFailedReqs,
-		AvgLatency:     totalAvgLatency / time.Duration(count),
-		P95Latency:     totalP95Latency / time.Duration(count),
-		P99Latency:     totalP99Latency / time.Duration(count),
+		AvgLatency:     time.Duration(merged.Mean()),
+		StdDevLatency:  time.Duration(merged.StdDev()),
+		MinLatency:     time.Duration(merged.Min()),
+		MaxLatency:     time.Duration(merged.Max()),
+		P50Latency:     time.Duration(merged.ValueAtPercentile(50)),
+		P90Latency:     time.Duration(merged.ValueAtPercentile(90)),
+		P95Latency:     time.Duration(merged.ValueAtPercentile(95)),
+		P99Latency:     time.Duration(merged.ValueAtPercentile(99)),
+		P999Latency:    time.Duration(merged.ValueAtPercentile(99.9)),
+		P9999Latency:   time.Duration(merged.ValueAtPercentile(99.99)),
 		Throughput:     totalThroughput / float64(count),
 		ErrorRate:      totalErrorRate / float64(count),
+		Histogram:      merged,
 	}
 }
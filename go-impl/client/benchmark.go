@@ -12,9 +12,46 @@ import (
 	"google.golang.org/grpc/status"
 
 	"grpc-over-http3/common"
+	"grpc-over-http3/hdr"
 	pb "grpc-over-http3/proto"
 )
 
+// histogramLowest/histogramHighest/histogramSigFigs size the per-connection
+// hdr.Histogram: 1us to 60s covers everything from a local echo to a stalled
+// request under heavy impairment, and 3 significant figures keeps the bucket
+// count fixed (18,432 counters) regardless of how many requests are recorded.
+const (
+	histogramLowest  = int64(time.Microsecond)
+	histogramHighest = int64(60 * time.Second)
+	histogramSigFigs = 3
+)
+
+// BenchmarkMode selects which EchoService RPC a benchmark run exercises.
+// Unary is handled by runHTTP2Benchmark/runHTTP3Benchmark; ServerStreamMode
+// and BidiStreamMode are handled by runStreamBenchmark; AltSvcUpgradeMode is
+// handled by runAltSvcBenchmark.
+type BenchmarkMode int
+
+const (
+	Unary BenchmarkMode = iota
+	ServerStreamMode
+	BidiStreamMode
+	AltSvcUpgradeMode
+)
+
+func (m BenchmarkMode) String() string {
+	switch m {
+	case ServerStreamMode:
+		return "ServerStream"
+	case BidiStreamMode:
+		return "BidiStream"
+	case AltSvcUpgradeMode:
+		return "AltSvcUpgrade"
+	default:
+		return "Unary"
+	}
+}
+
 type BenchmarkConfig struct {
 	Requests      int
 	Connections   int
@@ -23,6 +60,34 @@ type BenchmarkConfig struct {
 	Protocol      string // "HTTP/2" or "HTTP/3"
 	ServerAddr    string
 	TestCases     []TestCase
+
+	// Mode selects Unary (default), ServerStreamMode, or BidiStreamMode.
+	// The stream modes use MessagesPerStream/InFlightWindow/SendInterval
+	// below instead of Requests/Connections' per-call semantics - see
+	// runStreamBenchmark.
+	Mode BenchmarkMode
+
+	// MessagesPerStream is, for ServerStreamMode, how many responses the
+	// client reads before canceling the call (the handler streams until
+	// canceled), and for BidiStreamMode, how many requests the client
+	// sends on each stream.
+	MessagesPerStream int
+
+	// InFlightWindow bounds how many BidiStreamMode messages may be sent
+	// but not yet acknowledged at once, so a stalled receive side applies
+	// backpressure instead of the client buffering unboundedly.
+	InFlightWindow int
+
+	// SendInterval paces BidiStreamMode sends; zero sends as fast as the
+	// in-flight window allows.
+	SendInterval time.Duration
+
+	// SpanExporter, if set, receives one common.Span per request from
+	// runHTTP2Benchmark/runHTTP3Benchmark, tagged with a fresh request ID -
+	// this is what ships per-request latencies to an OTLP collector
+	// alongside the CSV/JSONL output written at the end of the run. Nil
+	// disables span export entirely, at no extra cost per request.
+	SpanExporter common.SpanExporter
 }
 
 type TestCase struct {
@@ -30,20 +95,68 @@ type TestCase struct {
 	Loss  int // percentage
 }
 
+// BenchmarkResult's json tags give nanosecond-precision time.Duration
+// fields (Go's default int64 marshaling, not rounded milliseconds) so
+// generateJSONLReport's output can be re-aggregated downstream without
+// having already lost precision to millisecond rounding the way the CSV
+// and Markdown reports do for readability.
 type BenchmarkResult struct {
-	TotalRequests  int
-	SuccessfulReqs int
-	FailedReqs     int
-	TotalTime      time.Duration
-	AvgLatency     time.Duration
-	MinLatency     time.Duration
-	MaxLatency     time.Duration
-	P95Latency     time.Duration
-	P99Latency     time.Duration
-	Throughput     float64 // requests/second
-	ErrorRate      float64
-	Protocol       string
-	TestCase       TestCase
+	TotalRequests  int           `json:"total_requests"`
+	SuccessfulReqs int           `json:"successful_reqs"`
+	FailedReqs     int           `json:"failed_reqs"`
+	TotalTime      time.Duration `json:"total_time_ns"`
+	AvgLatency     time.Duration `json:"avg_latency_ns"`
+	StdDevLatency  time.Duration `json:"stddev_latency_ns"`
+	MinLatency     time.Duration `json:"min_latency_ns"`
+	MaxLatency     time.Duration `json:"max_latency_ns"`
+	P50Latency     time.Duration `json:"p50_latency_ns"`
+	P90Latency     time.Duration `json:"p90_latency_ns"`
+	P95Latency     time.Duration `json:"p95_latency_ns"`
+	P99Latency     time.Duration `json:"p99_latency_ns"`
+	P999Latency    time.Duration `json:"p999_latency_ns"`
+	P9999Latency   time.Duration `json:"p9999_latency_ns"`
+	Throughput     float64       `json:"throughput_rps"`
+	ErrorRate      float64       `json:"error_rate"`
+	Protocol       string        `json:"protocol"`
+	TestCase       TestCase      `json:"test_case"`
+
+	// Histogram is the merged latency distribution behind the percentiles
+	// above, kept around so generatePerformanceReport can emit a full .hgrm
+	// CDF instead of just the handful of percentiles summarized here. It's
+	// excluded from JSON output since hdr.Histogram has no exported fields
+	// worth serializing - the .hgrm file is the histogram's export format.
+	Histogram *hdr.Histogram `json:"-"`
+
+	// Mode, StreamOpenLatency, MessagesPerStream and InFlightWindow are only
+	// populated by runStreamBenchmark; a Unary result leaves them zero.
+	Mode              BenchmarkMode `json:"mode"`
+	StreamOpenLatency time.Duration `json:"stream_open_latency_ns"`
+	MessagesPerStream int           `json:"messages_per_stream"`
+	InFlightWindow    int           `json:"in_flight_window"`
+
+	// UpgradedRequests and AvgUpgradeLatency are only populated by
+	// runAltSvcBenchmark: UpgradedRequests counts how many requests an
+	// AltSvcCache entry let skip straight to HTTP/3, out of TotalRequests;
+	// AvgUpgradeLatency is those upgraded requests' mean latency, so it can
+	// be compared directly against the cold HTTP/2 request that discovered
+	// the Alt-Svc advertisement in the first place.
+	UpgradedRequests  int           `json:"upgraded_requests"`
+	AvgUpgradeLatency time.Duration `json:"avg_upgrade_latency_ns"`
+
+	// ObservedImpairment is the router's actual `tc qdisc show` state at
+	// the time this result's test case ran (see network_control.go's
+	// observedNetworkConditions), kept alongside TestCase's requested
+	// delay/loss so a report can show what was asked for next to what was
+	// really applied.
+	ObservedImpairment Impairment `json:"observed_impairment"`
+
+	// Invalid marks a result whose server wasn't gRPC-health-SERVING for
+	// the entire run (see checkServiceHealth in health.go) - without this,
+	// a server that failed to start or crashed mid-run produces an
+	// all-errors BenchmarkResult that still gets written to the CSV
+	// looking like a legitimate (if terrible) data point.
+	Invalid       bool   `json:"invalid"`
+	InvalidReason string `json:"invalid_reason,omitempty"`
 }
 
 // 進行状況を表示するヘルパー関数
@@ -73,6 +186,7 @@ func runHTTP2Benchmark(config BenchmarkConfig) BenchmarkResult {
 	start := time.Now()
 	var wg sync.WaitGroup
 	results := make(chan RequestResult, config.Requests)
+	histograms := make([]*hdr.Histogram, config.Connections)
 
 	requestsPerConnection := config.Requests / config.Connections
 	remainingRequests := config.Requests % config.Connections
@@ -82,6 +196,11 @@ func runHTTP2Benchmark(config BenchmarkConfig) BenchmarkResult {
 		go func(connID int) {
 			defer wg.Done()
 
+			// 各コネクションのゴルーチンが自分専用のヒストグラムに記録し、
+			// 完了後に analyzeResults でマージする（スライスに全件バッファしない）
+			h := hdr.New(histogramLowest, histogramHighest, histogramSigFigs)
+			histograms[connID] = h
+
 			// gRPC接続（証明書検証を無効化）
 			conn, err := grpc.Dial(fmt.Sprintf("%s:%d", config.ServerAddr, 443),
 				grpc.WithTransportCredentials(insecure.NewCredentials()),
@@ -89,7 +208,7 @@ func runHTTP2Benchmark(config BenchmarkConfig) BenchmarkResult {
 			if err != nil {
 				logger.Error("Failed to connect", "connection", connID, "error", err)
 				for j := 0; j < requestsPerConnection; j++ {
-					results <- RequestResult{Success: false, Latency: 0, Error: err}
+					results <- RequestResult{Success: false, Error: err}
 				}
 				return
 			}
@@ -109,10 +228,13 @@ func runHTTP2Benchmark(config BenchmarkConfig) BenchmarkResult {
 					Timestamp: time.Now().UnixNano(),
 				})
 				latency := time.Since(reqStart)
+				if err == nil {
+					h.RecordDuration(latency)
+				}
+				exportSpan(config.SpanExporter, "EchoService.Echo", reqStart, latency, "HTTP/2", connID)
 
 				results <- RequestResult{
 					Success: err == nil,
-					Latency: latency,
 					Error:   err,
 				}
 
@@ -127,7 +249,7 @@ func runHTTP2Benchmark(config BenchmarkConfig) BenchmarkResult {
 	close(results)
 
 	totalTime := time.Since(start)
-	return analyzeResults(results, totalTime, "HTTP/2", TestCase{})
+	return analyzeResults(results, histograms, totalTime, "HTTP/2", TestCase{})
 }
 
 func runHTTP3Benchmark(config BenchmarkConfig) BenchmarkResult {
@@ -139,6 +261,7 @@ func runHTTP3Benchmark(config BenchmarkConfig) BenchmarkResult {
 	start := time.Now()
 	var wg sync.WaitGroup
 	results := make(chan RequestResult, config.Requests)
+	histograms := make([]*hdr.Histogram, config.Connections)
 
 	requestsPerConnection := config.Requests / config.Connections
 	remainingRequests := config.Requests % config.Connections
@@ -148,6 +271,9 @@ func runHTTP3Benchmark(config BenchmarkConfig) BenchmarkResult {
 		go func(connID int) {
 			defer wg.Done()
 
+			h := hdr.New(histogramLowest, histogramHighest, histogramSigFigs)
+			histograms[connID] = h
+
 			// gRPC接続（HTTP/3ポート、証明書検証を無効化）
 			conn, err := grpc.Dial(fmt.Sprintf("%s:%d", config.ServerAddr, 4433),
 				grpc.WithTransportCredentials(insecure.NewCredentials()),
@@ -155,7 +281,7 @@ func runHTTP3Benchmark(config BenchmarkConfig) BenchmarkResult {
 			if err != nil {
 				logger.Error("Failed to connect", "connection", connID, "error", err)
 				for j := 0; j < requestsPerConnection; j++ {
-					results <- RequestResult{Success: false, Latency: 0, Error: err}
+					results <- RequestResult{Success: false, Error: err}
 				}
 				return
 			}
@@ -175,10 +301,13 @@ func runHTTP3Benchmark(config BenchmarkConfig) BenchmarkResult {
 					Timestamp: time.Now().UnixNano(),
 				})
 				latency := time.Since(reqStart)
+				if err == nil {
+					h.RecordDuration(latency)
+				}
+				exportSpan(config.SpanExporter, "EchoService.Echo", reqStart, latency, "HTTP/3", connID)
 
 				results <- RequestResult{
 					Success: err == nil,
-					Latency: latency,
 					Error:   err,
 				}
 
@@ -193,18 +322,22 @@ func runHTTP3Benchmark(config BenchmarkConfig) BenchmarkResult {
 	close(results)
 
 	totalTime := time.Since(start)
-	return analyzeResults(results, totalTime, "HTTP/3", TestCase{})
+	return analyzeResults(results, histograms, totalTime, "HTTP/3", TestCase{})
 }
 
 type RequestResult struct {
 	Success bool
-	Latency time.Duration
 	Error   error
 }
 
-func analyzeResults(results <-chan RequestResult, totalTime time.Duration, protocol string, testCase TestCase) BenchmarkResult {
+// analyzeResults drains the per-request success/failure channel and merges
+// the per-connection latency histograms runHTTP2Benchmark/runHTTP3Benchmark
+// built up concurrently. It used to buffer every latency into a slice and
+// bubble-sort it for percentiles; at a few hundred thousand requests that
+// sort dominated wall-clock time and the slice alone could run into
+// gigabytes. Merging fixed-size histograms is O(1) per connection instead.
+func analyzeResults(results <-chan RequestResult, histograms []*hdr.Histogram, totalTime time.Duration, protocol string, testCase TestCase) BenchmarkResult {
 	logger := common.NewLogger("INFO")
-	var latencies []time.Duration
 	var successfulReqs, failedReqs int
 	var completed int
 
@@ -214,10 +347,9 @@ func analyzeResults(results <-chan RequestResult, totalTime time.Duration, proto
 		if completed%100 == 0 || completed%1000 == 0 {
 			logger.Info("Processing results", "completed", completed, "successful", successfulReqs, "failed", failedReqs)
 		}
-		
+
 		if result.Success {
 			successfulReqs++
-			latencies = append(latencies, result.Latency)
 		} else {
 			failedReqs++
 			if failedReqs <= 5 { // 最初の5個のエラーのみログ出力
@@ -225,7 +357,7 @@ func analyzeResults(results <-chan RequestResult, totalTime time.Duration, proto
 			}
 		}
 	}
-	
+
 	logger.Info("Result collection completed", "total", completed, "successful", successfulReqs, "failed", failedReqs)
 
 	totalRequests := successfulReqs + failedReqs
@@ -233,28 +365,16 @@ func analyzeResults(results <-chan RequestResult, totalTime time.Duration, proto
 		return BenchmarkResult{Protocol: protocol, TestCase: testCase}
 	}
 
-	// 統計計算
-	var totalLatency time.Duration
-	var minLatency, maxLatency time.Duration = time.Hour, 0
-
-	for _, latency := range latencies {
-		totalLatency += latency
-		if latency < minLatency {
-			minLatency = latency
+	merged := hdr.New(histogramLowest, histogramHighest, histogramSigFigs)
+	for _, h := range histograms {
+		if h == nil {
+			continue
 		}
-		if latency > maxLatency {
-			maxLatency = latency
+		if err := merged.Merge(h); err != nil {
+			logger.Error("Failed to merge connection histogram", "error", err)
 		}
 	}
 
-	avgLatency := time.Duration(0)
-	if len(latencies) > 0 {
-		avgLatency = totalLatency / time.Duration(len(latencies))
-	}
-
-	// パーセンタイル計算
-	p95Latency, p99Latency := calculatePercentiles(latencies)
-
 	throughput := float64(successfulReqs) / totalTime.Seconds()
 	errorRate := float64(failedReqs) / float64(totalRequests)
 
@@ -263,43 +383,43 @@ func analyzeResults(results <-chan RequestResult, totalTime time.Duration, proto
 		SuccessfulReqs: successfulReqs,
 		FailedReqs:     failedReqs,
 		TotalTime:      totalTime,
-		AvgLatency:     avgLatency,
-		MinLatency:     minLatency,
-		MaxLatency:     maxLatency,
-		P95Latency:     p95Latency,
-		P99Latency:     p99Latency,
+		AvgLatency:     time.Duration(merged.Mean()),
+		StdDevLatency:  time.Duration(merged.StdDev()),
+		MinLatency:     time.Duration(merged.Min()),
+		MaxLatency:     time.Duration(merged.Max()),
+		P50Latency:     time.Duration(merged.ValueAtPercentile(50)),
+		P90Latency:     time.Duration(merged.ValueAtPercentile(90)),
+		P95Latency:     time.Duration(merged.ValueAtPercentile(95)),
+		P99Latency:     time.Duration(merged.ValueAtPercentile(99)),
+		P999Latency:    time.Duration(merged.ValueAtPercentile(99.9)),
+		P9999Latency:   time.Duration(merged.ValueAtPercentile(99.99)),
 		Throughput:     throughput,
 		ErrorRate:      errorRate,
 		Protocol:       protocol,
 		TestCase:       testCase,
+		Histogram:      merged,
 	}
 }
 
-func calculatePercentiles(latencies []time.Duration) (time.Duration, time.Duration) {
-	if len(latencies) == 0 {
-		return 0, 0
-	}
-
-	// ソート
-	for i := 0; i < len(latencies); i++ {
-		for j := i + 1; j < len(latencies); j++ {
-			if latencies[i] > latencies[j] {
-				latencies[i], latencies[j] = latencies[j], latencies[i]
-			}
-		}
+// exportSpan hands one request's timing to exporter as a common.Span, if
+// exporter is set. Export errors are swallowed (not logged per-request) -
+// a benchmark run exercising thousands of requests a second can't afford to
+// log every dropped span, and a single unreachable collector shouldn't be
+// able to slow down or fail the benchmark it's meant to be observing.
+func exportSpan(exporter common.SpanExporter, name string, start time.Time, latency time.Duration, protocol string, connID int) {
+	if exporter == nil {
+		return
 	}
-
-	p95Index := int(float64(len(latencies)) * 0.95)
-	p99Index := int(float64(len(latencies)) * 0.99)
-
-	if p95Index >= len(latencies) {
-		p95Index = len(latencies) - 1
-	}
-	if p99Index >= len(latencies) {
-		p99Index = len(latencies) - 1
-	}
-
-	return latencies[p95Index], latencies[p99Index]
+	exporter.Export(common.Span{
+		Name:      name,
+		RequestID: common.NewRequestID(),
+		StartTime: start,
+		Duration:  latency,
+		Attrs: map[string]string{
+			"protocol":   protocol,
+			"connection": fmt.Sprintf("%d", connID),
+		},
+	})
 }
 
 func isRetryableError(err error) bool {
@@ -1,17 +1,45 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
 	"grpc-over-http3/common"
 )
 
+// spanExportBufferSize bounds how many spans can queue in the
+// AsyncSpanExporter before Export starts dropping them, comfortably ahead
+// of a single connection's request rate so a brief collector hiccup
+// doesn't lose spans.
+const spanExportBufferSize = 4096
+
 func main() {
-	logger := common.NewLogger("INFO")
+	formatFlag := flag.String("format", "csv,md", "comma-separated output formats to write: csv,jsonl,prom,md")
+	otlpEndpoint := flag.String("otlp-endpoint", "", "OTLP/HTTP collector endpoint (e.g. http://otel-collector:4318/v1/traces) to export per-request spans to; unset disables span export")
+	configPath := flag.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML file (see common.BenchmarkFileConfig) overriding the hardcoded request/connection counts and test-case matrix below")
+	flag.Parse()
+	formats := parseFormats(*formatFlag)
+
+	// ログディレクトリ作成
+	timestamp := time.Now().Format("20060102_150405")
+	logDir := filepath.Join("/logs", fmt.Sprintf("benchmark_%s", timestamp))
+	if err := os.MkdirAll(logDir, 0755); err != nil {
+		log.Fatalf("Failed to create log directory: %v", err)
+	}
+
+	// ログはstdoutに加え、logDir配下へローテーションしながら書き込む
+	logger, logCloser, err := common.NewFileLogger("INFO", logDir)
+	if err != nil {
+		log.Fatalf("Failed to create logger: %v", err)
+	}
+	defer logCloser.Close()
 	logger.Info("Starting gRPC over HTTP/2 and HTTP/3 benchmark client")
 
 	// ベンチマーク設定（高速化設定）
@@ -29,11 +57,21 @@ func main() {
 		},
 	}
 
-	// ログディレクトリ作成
-	timestamp := time.Now().Format("20060102_150405")
-	logDir := filepath.Join("/logs", fmt.Sprintf("benchmark_%s", timestamp))
-	if err := os.MkdirAll(logDir, 0755); err != nil {
-		log.Fatalf("Failed to create log directory: %v", err)
+	if *configPath != "" {
+		fileConfig, err := common.LoadBenchmarkFileConfig(*configPath)
+		if err != nil {
+			log.Fatalf("Invalid benchmark config file %s: %v", *configPath, err)
+		}
+		config = applyBenchmarkFileConfig(config, fileConfig)
+		logger.Info("Loaded benchmark config from file", "path", *configPath, "test_cases", len(config.TestCases))
+	}
+
+	if *otlpEndpoint != "" {
+		// AsyncSpanExporter ships spans from a background goroutine so a
+		// slow/unreachable collector can't add its own latency to the
+		// per-request timings this benchmark exists to measure.
+		config.SpanExporter = common.NewAsyncSpanExporter(common.NewOTLPSpanExporter(*otlpEndpoint), spanExportBufferSize)
+		logger.Info("Span export enabled", "otlp_endpoint", *otlpEndpoint)
 	}
 
 	var allResults []BenchmarkResult
@@ -54,19 +92,43 @@ func main() {
 			continue
 		}
 
+		observed, err := observedNetworkConditions()
+		if err != nil {
+			logger.Warn("Failed to read observed network conditions from router", "error", err)
+		} else {
+			logger.Info("Observed network conditions", "delay_ms", observed.DelayMs, "loss_pct", observed.LossPct)
+		}
+
 		// システム安定化（短縮）
 		logger.Info("Phase 1: Stabilizing system", "duration", "5s")
 		time.Sleep(5 * time.Second)
 
 		// HTTP/2 ベンチマーク
 		logger.Info("Phase 2: Running HTTP/2 benchmark")
+		http2Addr := fmt.Sprintf("%s:443", config.ServerAddr)
+		preStatus, preErr := checkServiceHealth(http2Addr)
+		if preErr != nil {
+			logger.WithHealth("HTTP/2", healthpb.HealthCheckResponse_UNKNOWN).Warn("Pre-run health check failed", "error", preErr)
+		}
+
 		http2Config := config
 		http2Config.Protocol = "HTTP/2"
 		http2Result := runHTTP2Benchmark(http2Config)
 		http2Result.TestCase = testCase
+		http2Result.ObservedImpairment = observed
+
+		postStatus, postErr := checkServiceHealth(http2Addr)
+		if postErr != nil {
+			logger.WithHealth("HTTP/2", healthpb.HealthCheckResponse_UNKNOWN).Warn("Post-run health check failed", "error", postErr)
+		}
+		if preErr != nil || postErr != nil || preStatus != healthpb.HealthCheckResponse_SERVING || postStatus != healthpb.HealthCheckResponse_SERVING {
+			http2Result.Invalid = true
+			http2Result.InvalidReason = fmt.Sprintf("server not SERVING for the whole run (pre=%s, post=%s)", preStatus, postStatus)
+			logger.WithHealth("HTTP/2", postStatus).Error("Marking result invalid", "reason", http2Result.InvalidReason)
+		}
 		allResults = append(allResults, http2Result)
-		
-		logger.Info("HTTP/2 benchmark completed", 
+
+		logger.Info("HTTP/2 benchmark completed",
 			"successful", http2Result.SuccessfulReqs, 
 			"failed", http2Result.FailedReqs,
 			"throughput", fmt.Sprintf("%.2f req/s", http2Result.Throughput))
@@ -83,6 +145,18 @@ func main() {
 		// http3Result.TestCase = testCase
 		// allResults = append(allResults, http3Result)
 
+		// Alt-Svc駆動のHTTP/2→HTTP/3自動アップグレードベンチマーク
+		logger.Info("Phase 4: Running Alt-Svc upgrade benchmark")
+		altSvcResult := runAltSvcBenchmark(config)
+		altSvcResult.TestCase = testCase
+		altSvcResult.ObservedImpairment = observed
+		allResults = append(allResults, altSvcResult)
+
+		logger.Info("Alt-Svc upgrade benchmark completed",
+			"upgraded", altSvcResult.UpgradedRequests,
+			"total", altSvcResult.TotalRequests,
+			"avg_upgrade_latency", altSvcResult.AvgUpgradeLatency)
+
 		// テストケース間の間隔（短縮）
 		logger.Info("Test case completed", "delay", testCase.Delay, "loss", testCase.Loss)
 		logger.Info("Waiting between test cases", "duration", "3s")
@@ -93,19 +167,40 @@ func main() {
 	logger.Info("All test cases completed", "total_results", len(allResults))
 	logger.Info("================================================")
 
-	// 結果をCSVで出力
-	csvFile := filepath.Join(logDir, "benchmark_results.csv")
-	if err := generateCSVReport(allResults, csvFile); err != nil {
-		logger.Error("Failed to generate CSV report", "error", err)
-	} else {
-		logger.Info("CSV report generated", "file", csvFile)
+	// 結果を指定されたフォーマットで出力
+	if formats["csv"] {
+		csvFile := filepath.Join(logDir, "benchmark_results.csv")
+		if err := generateCSVReport(allResults, csvFile); err != nil {
+			logger.Error("Failed to generate CSV report", "error", err)
+		} else {
+			logger.Info("CSV report generated", "file", csvFile)
+		}
 	}
 
-	// パフォーマンスレポート生成
-	if err := generatePerformanceReport(allResults, logDir); err != nil {
-		logger.Error("Failed to generate performance report", "error", err)
-	} else {
-		logger.Info("Performance report generated", "directory", logDir)
+	if formats["jsonl"] {
+		jsonlFile := filepath.Join(logDir, "benchmark_results.jsonl")
+		if err := generateJSONLReport(allResults, jsonlFile); err != nil {
+			logger.Error("Failed to generate JSONL report", "error", err)
+		} else {
+			logger.Info("JSONL report generated", "file", jsonlFile)
+		}
+	}
+
+	if formats["prom"] {
+		promFile := filepath.Join(logDir, "benchmark_results.prom")
+		if err := generatePromReport(allResults, promFile); err != nil {
+			logger.Error("Failed to generate Prometheus report", "error", err)
+		} else {
+			logger.Info("Prometheus report generated", "file", promFile)
+		}
+	}
+
+	if formats["md"] {
+		if err := generatePerformanceReport(allResults, logDir); err != nil {
+			logger.Error("Failed to generate performance report", "error", err)
+		} else {
+			logger.Info("Performance report generated", "directory", logDir)
+		}
 	}
 
 	// 結果サマリー出力
@@ -115,13 +210,52 @@ func main() {
 	printSummary(allResults)
 }
 
-func setNetworkConditions(delay, loss int) error {
-	// ルーターコンテナにネットワーク条件を設定するコマンドを送信
-	// 実際の実装では、ルーターコンテナとの通信を行う
-	log.Printf("Setting network conditions: delay=%dms, loss=%d%%", delay, loss)
-	return nil
+// applyBenchmarkFileConfig overlays fileConfig's fields onto base, returning
+// the merged BenchmarkConfig. Zero-valued fileConfig fields (including a nil
+// TestCases slice) leave base's corresponding field untouched, so a file
+// that only sets test_cases doesn't also have to repeat Requests/Connections
+// just to avoid zeroing them out.
+func applyBenchmarkFileConfig(base BenchmarkConfig, fileConfig *common.BenchmarkFileConfig) BenchmarkConfig {
+	if fileConfig.Requests > 0 {
+		base.Requests = fileConfig.Requests
+	}
+	if fileConfig.Connections > 0 {
+		base.Connections = fileConfig.Connections
+	}
+	if fileConfig.Threads > 0 {
+		base.Threads = fileConfig.Threads
+	}
+	if fileConfig.MaxConcurrent > 0 {
+		base.MaxConcurrent = fileConfig.MaxConcurrent
+	}
+	if fileConfig.ServerAddr != "" {
+		base.ServerAddr = fileConfig.ServerAddr
+	}
+	if len(fileConfig.TestCases) > 0 {
+		testCases := make([]TestCase, len(fileConfig.TestCases))
+		for i, tc := range fileConfig.TestCases {
+			testCases[i] = TestCase{Delay: tc.DelayMs, Loss: tc.LossPct}
+		}
+		base.TestCases = testCases
+	}
+	return base
+}
+
+// parseFormats splits a comma-separated --format value into a lookup set,
+// trimming whitespace and ignoring empty entries so "csv, jsonl," works the
+// same as "csv,jsonl".
+func parseFormats(flagValue string) map[string]bool {
+	formats := make(map[string]bool)
+	for _, f := range strings.Split(flagValue, ",") {
+		f = strings.TrimSpace(f)
+		if f != "" {
+			formats[f] = true
+		}
+	}
+	return formats
 }
 
+
 func printSummary(results []BenchmarkResult) {
 	logger := common.NewLogger("INFO")
 
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// echoServiceHealthName must match the name server/server.go registers its
+// grpc/health service status under.
+const echoServiceHealthName = "echo.EchoService"
+
+// healthProbeTimeout bounds a single checkServiceHealth call so a hung
+// server fails the check quickly instead of stalling the whole sweep.
+const healthProbeTimeout = 2 * time.Second
+
+// checkServiceHealth dials addr and checks EchoService's status via the
+// standard gRPC health protocol, used to bracket each benchmark run so a
+// server that failed to start or crashed mid-run can be told apart from one
+// that served every request and happened to be slow.
+func checkServiceHealth(addr string) (healthpb.HealthCheckResponse_ServingStatus, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), healthProbeTimeout)
+	defer cancel()
+
+	conn, err := grpc.DialContext(ctx, addr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithBlock())
+	if err != nil {
+		return healthpb.HealthCheckResponse_UNKNOWN, fmt.Errorf("failed to dial %s: %v", addr, err)
+	}
+	defer conn.Close()
+
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: echoServiceHealthName})
+	if err != nil {
+		return healthpb.HealthCheckResponse_UNKNOWN, fmt.Errorf("health check against %s failed: %v", addr, err)
+	}
+	return resp.Status, nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// routerAddr is the network-emulation router's control-plane address.
+// latency_benchmark.go's setRouterNetworkConfig hardcodes the same
+// 172.31.0.254:8080 - the two benchmark binaries run against the same
+// docker network topology, just driving different RPC transports.
+const routerAddr = "172.31.0.254:8080"
+
+// Impairment mirrors router/network_emulation.go's Impairment - the two
+// can't share a Go type since each lives in its own package main, but the
+// JSON field names must match what the router's /network/config and
+// /network/status use.
+type Impairment struct {
+	DelayMs         int     `json:"delay_ms"`
+	JitterMs        int     `json:"jitter_ms"`
+	JitterDist      string  `json:"jitter_distribution"`
+	LossPct         float64 `json:"loss_pct"`
+	LossCorrelation float64 `json:"loss_correlation"`
+	DuplicatePct    float64 `json:"duplicate_pct"`
+	ReorderPct      float64 `json:"reorder_pct"`
+	CorruptPct      float64 `json:"corrupt_pct"`
+	BandwidthKbps   int     `json:"bandwidth_kbps"`
+	QueueLimit      int     `json:"queue_limit"`
+}
+
+// NetworkConfigRequest mirrors router/router.go's NetworkConfigRequest.
+type NetworkConfigRequest struct {
+	Up         Impairment `json:"up"`
+	Down       Impairment `json:"down"`
+	Asymmetric bool       `json:"asymmetric"`
+}
+
+// NetworkStatusResponse mirrors router/router.go's NetworkStatusResponse -
+// Up/Down are what GetStatus actually parsed out of `tc qdisc show`, not
+// what was last requested.
+type NetworkStatusResponse struct {
+	Up         Impairment `json:"up"`
+	Down       Impairment `json:"down"`
+	Asymmetric bool       `json:"asymmetric"`
+}
+
+// setNetworkConditions pushes delay/loss to the router's /network/config, so
+// a benchmark test case actually runs under the impairment its TestCase
+// claims instead of the ambient network. It replaces a stub that only
+// logged the intent.
+func setNetworkConditions(delay, loss int) error {
+	config := NetworkConfigRequest{
+		Up: Impairment{
+			DelayMs: delay,
+			LossPct: float64(loss),
+		},
+	}
+
+	jsonData, err := json.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("failed to marshal network config: %v", err)
+	}
+
+	resp, err := http.Post(fmt.Sprintf("http://%s/network/config", routerAddr),
+		"application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to set network config: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("router returned status %d: %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+// observedNetworkConditions fetches /network/status, which - unlike the
+// TestCase a benchmark run was asked to apply - reflects what `tc qdisc
+// show` actually reports on the router, so a report can show "requested
+// 75ms/3% loss, observed 74.6ms/2.9% loss" instead of just echoing the ask.
+func observedNetworkConditions() (Impairment, error) {
+	resp, err := http.Get(fmt.Sprintf("http://%s/network/status", routerAddr))
+	if err != nil {
+		return Impairment{}, fmt.Errorf("failed to get network status: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return Impairment{}, fmt.Errorf("router returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var status NetworkStatusResponse
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return Impairment{}, fmt.Errorf("failed to decode network status: %v", err)
+	}
+	return status.Up, nil
+}
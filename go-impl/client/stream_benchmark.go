@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"grpc-over-http3/common"
+	"grpc-over-http3/hdr"
+	pb "grpc-over-http3/proto"
+)
+
+// runStreamBenchmark opens config.Connections long-lived EchoService streams
+// (ServerStreamMode or BidiStreamMode) instead of runHTTP2Benchmark/
+// runHTTP3Benchmark's one-call-per-request loop, so stream multiplexing and
+// per-message latency under the router's loss emulation can be measured
+// directly. It reuses the same per-connection hdr.Histogram-and-merge
+// approach as the unary path, plus a second histogram for stream-open
+// latency, which unary calls have no equivalent of.
+func runStreamBenchmark(config BenchmarkConfig) BenchmarkResult {
+	logger := common.NewLogger("INFO")
+	logger.Info("================================================")
+	logger.Info("Starting stream benchmark", "mode", config.Mode, "connections", config.Connections, "messages_per_stream", config.MessagesPerStream)
+	logger.Info("================================================")
+
+	port := 443
+	if config.Protocol == "HTTP/3" {
+		port = 4433
+	}
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	messageHistograms := make([]*hdr.Histogram, config.Connections)
+	openHistograms := make([]*hdr.Histogram, config.Connections)
+	results := make(chan RequestResult, config.Connections*config.MessagesPerStream)
+
+	for i := 0; i < config.Connections; i++ {
+		wg.Add(1)
+		go func(connID int) {
+			defer wg.Done()
+
+			messageHist := hdr.New(histogramLowest, histogramHighest, histogramSigFigs)
+			openHist := hdr.New(histogramLowest, histogramHighest, histogramSigFigs)
+			messageHistograms[connID] = messageHist
+			openHistograms[connID] = openHist
+
+			conn, err := grpc.Dial(fmt.Sprintf("%s:%d", config.ServerAddr, port),
+				grpc.WithTransportCredentials(insecure.NewCredentials()),
+				grpc.WithBlock())
+			if err != nil {
+				logger.Error("Failed to connect", "connection", connID, "error", err)
+				for j := 0; j < config.MessagesPerStream; j++ {
+					results <- RequestResult{Success: false, Error: err}
+				}
+				return
+			}
+			defer conn.Close()
+
+			client := pb.NewEchoServiceClient(conn)
+
+			var streamErr error
+			switch config.Mode {
+			case ServerStreamMode:
+				streamErr = runServerStream(client, config, connID, messageHist, openHist, results)
+			case BidiStreamMode:
+				streamErr = runBidiStream(client, config, connID, messageHist, openHist, results)
+			default:
+				streamErr = fmt.Errorf("unsupported stream mode %v", config.Mode)
+			}
+			if streamErr != nil {
+				logger.Error("Stream failed", "connection", connID, "error", streamErr)
+			}
+		}(i)
+	}
+
+	wg.Wait()
+	close(results)
+
+	totalTime := time.Since(start)
+	result := analyzeResults(results, messageHistograms, totalTime, config.Protocol, TestCase{})
+	result.Mode = config.Mode
+	result.MessagesPerStream = config.MessagesPerStream
+	result.InFlightWindow = config.InFlightWindow
+
+	openMerged := hdr.New(histogramLowest, histogramHighest, histogramSigFigs)
+	for _, h := range openHistograms {
+		if h != nil {
+			openMerged.Merge(h)
+		}
+	}
+	result.StreamOpenLatency = time.Duration(openMerged.Mean())
+
+	return result
+}
+
+// runServerStream opens one EchoService.ServerStream call and reads
+// MessagesPerStream responses before canceling it (the handler otherwise
+// streams until the client gives up), recording the gap between
+// consecutive receives as each message's latency.
+func runServerStream(client pb.EchoServiceClient, config BenchmarkConfig, connID int, messageHist, openHist *hdr.Histogram, results chan<- RequestResult) error {
+	openStart := time.Now()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := client.ServerStream(ctx, &pb.EchoRequest{
+		Message:   fmt.Sprintf("stream-%d", connID),
+		Timestamp: time.Now().UnixNano(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to open server stream: %v", err)
+	}
+	openHist.RecordDuration(time.Since(openStart))
+
+	lastRecv := openStart
+	for j := 0; j < config.MessagesPerStream; j++ {
+		if _, err := stream.Recv(); err != nil {
+			results <- RequestResult{Success: false, Error: err}
+			return fmt.Errorf("failed to receive message %d: %v", j, err)
+		}
+		now := time.Now()
+		messageHist.RecordDuration(now.Sub(lastRecv))
+		lastRecv = now
+		results <- RequestResult{Success: true}
+	}
+
+	return nil
+}
+
+// runBidiStream opens one EchoService.BidiStream call and sends
+// MessagesPerStream requests while concurrently reading the echoed
+// responses, pacing sends by SendInterval and bounding how many messages
+// may be outstanding at once to InFlightWindow. Responses arrive in the
+// order requests were sent, so each receive is paired with the oldest
+// still-outstanding send timestamp to compute per-message latency.
+func runBidiStream(client pb.EchoServiceClient, config BenchmarkConfig, connID int, messageHist, openHist *hdr.Histogram, results chan<- RequestResult) error {
+	openStart := time.Now()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := client.BidiStream(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to open bidi stream: %v", err)
+	}
+	openHist.RecordDuration(time.Since(openStart))
+
+	window := config.InFlightWindow
+	if window < 1 {
+		window = 1
+	}
+	// The receiver holds one taken-but-unanswered timestamp outside the
+	// channel, so window-1 buffered slots cap outstanding sends at window.
+	inFlight := make(chan time.Time, window-1)
+
+	sendErr := make(chan error, 1)
+	go func() {
+		defer close(inFlight)
+		for j := 0; j < config.MessagesPerStream; j++ {
+			sendTime := time.Now()
+			inFlight <- sendTime // blocks once `window` sends are unacknowledged
+			if err := stream.Send(&pb.EchoRequest{
+				Message:   fmt.Sprintf("stream-%d-%d", connID, j),
+				Timestamp: sendTime.UnixNano(),
+			}); err != nil {
+				sendErr <- fmt.Errorf("failed to send message %d: %v", j, err)
+				return
+			}
+			if config.SendInterval > 0 {
+				time.Sleep(config.SendInterval)
+			}
+		}
+		stream.CloseSend()
+		sendErr <- nil
+	}()
+
+	for j := 0; j < config.MessagesPerStream; j++ {
+		sendTime, ok := <-inFlight
+		if !ok {
+			break
+		}
+		if _, err := stream.Recv(); err != nil {
+			results <- RequestResult{Success: false, Error: err}
+			return fmt.Errorf("failed to receive message %d: %v", j, err)
+		}
+		messageHist.RecordDuration(time.Since(sendTime))
+		results <- RequestResult{Success: true}
+	}
+
+	if err := <-sendErr; err != nil {
+		return err
+	}
+
+	return nil
+}
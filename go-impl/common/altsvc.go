@@ -0,0 +1,108 @@
+package common
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AltSvcEntry is one origin's cached HTTP/3 upgrade advertisement: the port
+// an HTTP/2 response's Alt-Svc header told us to dial h3 on, and when that
+// advertisement stops being trustworthy.
+type AltSvcEntry struct {
+	Port    int
+	Expires time.Time
+}
+
+// AltSvcCache remembers, per HTTP/2 origin ("host:port"), the most recent
+// h3 Alt-Svc advertisement seen for it - RFC 7838 scopes the advertisement
+// to the connection it arrived on, but in practice (and for this benchmark)
+// treating it as valid for the origin until ma expires is what lets a
+// client skip straight to HTTP/3 on its next request instead of re-probing
+// HTTP/2 every time.
+type AltSvcCache struct {
+	mu      sync.Mutex
+	entries map[string]AltSvcEntry
+}
+
+// NewAltSvcCache returns an empty cache ready to use.
+func NewAltSvcCache() *AltSvcCache {
+	return &AltSvcCache{entries: make(map[string]AltSvcEntry)}
+}
+
+// Observe parses an Alt-Svc header value as sent by altSvcMiddleware
+// (`h3=":4433"; ma=86400`) and records it for origin, replacing any
+// previous entry. Headers that don't carry an "h3" alternative, or that
+// fail to parse, are ignored rather than returned as an error - a missing
+// or malformed Alt-Svc header just means this response didn't advertise an
+// upgrade, which is routine, not exceptional.
+func (c *AltSvcCache) Observe(origin, headerValue string) {
+	port, maxAge, ok := parseAltSvcH3(headerValue)
+	if !ok {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[origin] = AltSvcEntry{
+		Port:    port,
+		Expires: time.Now().Add(maxAge),
+	}
+}
+
+// Lookup returns the cached h3 port for origin, if one was observed and its
+// ma hasn't expired yet.
+func (c *AltSvcCache) Lookup(origin string) (int, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[origin]
+	if !ok || time.Now().After(entry.Expires) {
+		return 0, false
+	}
+	return entry.Port, true
+}
+
+// parseAltSvcH3 extracts the port and max-age from the first `h3="..."` (or
+// unquoted host-less `h3=":port"`) alternative in an Alt-Svc header value.
+// It only understands the single-alternative, same-host form
+// altSvcMiddleware emits; a real Alt-Svc header can list several
+// alternatives and parameters comma-separated, which this benchmark's
+// server never does, so parsing that generality wasn't worth it here.
+func parseAltSvcH3(headerValue string) (port int, maxAge time.Duration, ok bool) {
+	maxAge = 24 * time.Hour // RFC 7838 default when ma is absent
+
+	for _, part := range strings.Split(headerValue, ";") {
+		part = strings.TrimSpace(part)
+
+		switch {
+		case strings.HasPrefix(part, "h3="):
+			value := strings.Trim(strings.TrimPrefix(part, "h3="), `"`)
+			host, portStr, found := strings.Cut(value, ":")
+			if !found {
+				portStr = host
+			}
+			p, err := strconv.Atoi(portStr)
+			if err != nil {
+				return 0, 0, false
+			}
+			port = p
+			ok = true
+		case strings.HasPrefix(part, "ma="):
+			seconds, err := strconv.Atoi(strings.TrimPrefix(part, "ma="))
+			if err == nil {
+				maxAge = time.Duration(seconds) * time.Second
+			}
+		}
+	}
+
+	return port, maxAge, ok
+}
+
+// Origin builds the "host:port" key AltSvcCache uses for host and the port
+// an HTTP/2 request was made to.
+func Origin(host string, port int) string {
+	return fmt.Sprintf("%s:%d", host, port)
+}
@@ -0,0 +1,80 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// BenchmarkTestCase mirrors client/benchmark.go's TestCase - the two can't
+// share a Go type since common and the client binary are separate packages
+// (see network_emulation.go's Impairment for the same pattern), but the YAML
+// field names must match what an operator writes in a benchmark config file.
+type BenchmarkTestCase struct {
+	DelayMs int `yaml:"delay_ms"`
+	LossPct int `yaml:"loss_pct"`
+}
+
+// BenchmarkFileConfig is the test-case-matrix block of a benchmark client's
+// --config file: the handful of run parameters client/client.go otherwise
+// hardcodes in main(), pulled out so a sweep across delay/loss points (or a
+// different request/connection count) doesn't need a rebuild.
+type BenchmarkFileConfig struct {
+	Requests      int                 `yaml:"requests"`
+	Connections   int                 `yaml:"connections"`
+	Threads       int                 `yaml:"threads"`
+	MaxConcurrent int                 `yaml:"max_concurrent"`
+	ServerAddr    string              `yaml:"server_addr"`
+	TestCases     []BenchmarkTestCase `yaml:"test_cases"`
+}
+
+// LoadBenchmarkFileConfig reads and validates path as a BenchmarkFileConfig.
+// The caller (client/client.go's applyBenchmarkFileConfig) overlays the
+// result onto its own hardcoded defaults field-by-field, so a file is free
+// to set only a subset of fields (e.g. just test_cases); Validate here only
+// range-checks the fields the file actually sets, not the ones it leaves
+// zero for the merge to fill in.
+func LoadBenchmarkFileConfig(path string) (*BenchmarkFileConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read benchmark config file %s: %v", path, err)
+	}
+
+	var config BenchmarkFileConfig
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse benchmark config file %s: %v", path, err)
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// Validate range-checks a BenchmarkFileConfig the same way Config.Validate
+// does, aggregating every violation via errors.Join. Requests/Connections
+// are only checked when the file actually sets them (non-zero) - a file
+// that leaves one at its zero value is deferring to the caller's base
+// config, not requesting an invalid one.
+func (c *BenchmarkFileConfig) Validate() error {
+	var errs []error
+
+	if c.Requests != 0 && c.Requests < 1 {
+		errs = append(errs, fmt.Errorf("requests must be >= 1, got %d", c.Requests))
+	}
+	if c.Connections != 0 && c.Connections < 1 {
+		errs = append(errs, fmt.Errorf("connections must be >= 1, got %d", c.Connections))
+	}
+	for i, tc := range c.TestCases {
+		if tc.DelayMs < 0 {
+			errs = append(errs, fmt.Errorf("test_cases[%d].delay_ms must be >= 0, got %d", i, tc.DelayMs))
+		}
+		if tc.LossPct < 0 || tc.LossPct > 100 {
+			errs = append(errs, fmt.Errorf("test_cases[%d].loss_pct must be in [0,100], got %d", i, tc.LossPct))
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -1,24 +1,40 @@
 package common
 
 import (
+	"errors"
+	"fmt"
 	"os"
 	"strconv"
+
+	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
-	ServerPort     int
-	HTTP3Port      int
-	CertPath       string
-	KeyPath        string
-	LogLevel       string
-	MaxConnections int
-	BatchSize      int
-	NetworkDelay   int
-	NetworkLoss    int
+	ServerPort     int    `yaml:"server_port"`
+	HTTP3Port      int    `yaml:"http3_port"`
+	CertPath       string `yaml:"cert_path"`
+	KeyPath        string `yaml:"key_path"`
+	LogLevel       string `yaml:"log_level"`
+	MaxConnections int    `yaml:"max_connections"`
+	BatchSize      int    `yaml:"batch_size"`
+	NetworkDelay   int    `yaml:"network_delay"`
+	NetworkLoss    int    `yaml:"network_loss"`
+
+	// RequireAddressValidation records that this server is meant to force
+	// QUIC's Retry mechanism, making every client prove ownership of its
+	// source address before the handshake proceeds. quic-go doesn't expose
+	// this as a quic.Config field - it's only reachable by constructing a
+	// quic.Transport with VerifySourceAddress set and driving the listener
+	// manually, which server.go's http3.Server.ListenAndServe doesn't do -
+	// so this field is not wired to any actual enforcement yet. Benchmarks
+	// still tag their own runs with --force-retry (see latency_benchmark.go)
+	// so results can be correlated against a server started with this set,
+	// once the enforcement side is implemented.
+	RequireAddressValidation bool `yaml:"require_address_validation"`
 }
 
-func LoadConfig() *Config {
-	config := &Config{
+func defaultConfig() *Config {
+	return &Config{
 		ServerPort:     443,
 		HTTP3Port:      4433,
 		CertPath:       "/certs/server.crt",
@@ -29,7 +45,46 @@ func LoadConfig() *Config {
 		NetworkDelay:   0,
 		NetworkLoss:    0,
 	}
+}
+
+// LoadConfig builds a Config layering, from lowest to highest precedence:
+// defaultConfig()'s hardcoded defaults, then configPath's YAML file (if
+// configPath is non-empty), then environment variables (SERVER_PORT,
+// HTTP3_PORT, LOG_LEVEL, NETWORK_DELAY, NETWORK_LOSS,
+// REQUIRE_ADDRESS_VALIDATION) - env vars win so an operator can override a
+// checked-in config file at deploy time without editing it. The result is
+// validated before being returned, so a caller only has to check one error
+// instead of separately validating every field it cares about.
+//
+// configPath is typically sourced from a --config flag or CONFIG_FILE env
+// var read by the caller's own flag parsing (see server/server.go) - common
+// doesn't parse flags itself, matching every other binary's main().
+func LoadConfig(configPath string) (*Config, error) {
+	config := defaultConfig()
 
+	if configPath != "" {
+		data, err := os.ReadFile(configPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file %s: %v", configPath, err)
+		}
+		if err := yaml.Unmarshal(data, config); err != nil {
+			return nil, fmt.Errorf("failed to parse config file %s: %v", configPath, err)
+		}
+	}
+
+	applyConfigEnv(config)
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// applyConfigEnv overlays the environment variables LoadConfig has always
+// recognized on top of config. A value that fails to parse is skipped: the
+// field keeps its default or file-set value, which is what Validate then
+// checks, and no error is reported for the malformed env var itself.
+func applyConfigEnv(config *Config) {
 	if port := os.Getenv("SERVER_PORT"); port != "" {
 		if p, err := strconv.Atoi(port); err == nil {
 			config.ServerPort = p
@@ -58,5 +113,41 @@ func LoadConfig() *Config {
 		}
 	}
 
-	return config
+	if requireValidation := os.Getenv("REQUIRE_ADDRESS_VALIDATION"); requireValidation != "" {
+		if v, err := strconv.ParseBool(requireValidation); err == nil {
+			config.RequireAddressValidation = v
+		}
+	}
+}
+
+// Validate range-checks every field LoadConfig populated, aggregating every
+// violation (via errors.Join) instead of stopping at the first, so a
+// malformed config file reports everything wrong with it in one pass rather
+// than forcing fix-rerun-fix cycles.
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.ServerPort < 1 || c.ServerPort > 65535 {
+		errs = append(errs, fmt.Errorf("server_port must be in [1,65535], got %d", c.ServerPort))
+	}
+	if c.HTTP3Port < 1 || c.HTTP3Port > 65535 {
+		errs = append(errs, fmt.Errorf("http3_port must be in [1,65535], got %d", c.HTTP3Port))
+	}
+	if c.CertPath == "" {
+		errs = append(errs, errors.New("cert_path must not be empty"))
+	}
+	if c.KeyPath == "" {
+		errs = append(errs, errors.New("key_path must not be empty"))
+	}
+	if c.NetworkDelay < 0 {
+		errs = append(errs, fmt.Errorf("network_delay must be >= 0, got %d", c.NetworkDelay))
+	}
+	if c.NetworkLoss < 0 || c.NetworkLoss > 100 {
+		errs = append(errs, fmt.Errorf("network_loss must be in [0,100], got %d", c.NetworkLoss))
+	}
+	if c.MaxConnections < 1 {
+		errs = append(errs, fmt.Errorf("max_connections must be >= 1, got %d", c.MaxConnections))
+	}
+
+	return errors.Join(errs...)
 }
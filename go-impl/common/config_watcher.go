@@ -0,0 +1,123 @@
+package common
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// ConfigWatcher fsnotify-watches a Config's backing YAML file (mirroring
+// cert.Watcher's approach to certificate files) and re-runs LoadConfig on
+// every write, notifying subscribers with the freshly loaded Config. Only
+// fields meant to be adjusted without a restart - LogLevel, NetworkDelay,
+// NetworkLoss, MaxConnections - are expected to actually change between
+// reloads; fields like CertPath or the listen ports take effect only on the
+// next process start regardless of how many times the file is edited.
+type ConfigWatcher struct {
+	path string
+
+	mu          sync.RWMutex
+	current     *Config
+	subscribers []func(*Config)
+
+	watcher *fsnotify.Watcher
+	done    chan struct{}
+}
+
+// NewConfigWatcher loads path once via LoadConfig and starts watching it for
+// subsequent changes. path must be non-empty - watching "no file" doesn't
+// make sense, unlike LoadConfig's own configPath, which is allowed to be
+// empty to mean "defaults and env vars only".
+func NewConfigWatcher(path string) (*ConfigWatcher, error) {
+	if path == "" {
+		return nil, fmt.Errorf("config watcher requires a non-empty path")
+	}
+
+	config, err := LoadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create fsnotify watcher: %v", err)
+	}
+	if err := fsw.Add(path); err != nil {
+		fsw.Close()
+		return nil, fmt.Errorf("failed to watch %s: %v", path, err)
+	}
+
+	cw := &ConfigWatcher{path: path, current: config, watcher: fsw, done: make(chan struct{})}
+	go cw.run()
+	return cw, nil
+}
+
+// Current returns the most recently loaded Config.
+func (cw *ConfigWatcher) Current() *Config {
+	cw.mu.RLock()
+	defer cw.mu.RUnlock()
+	return cw.current
+}
+
+// Subscribe registers fn to be called, with the freshly reloaded Config,
+// every time cw.path changes and reparses/revalidates successfully. fn is
+// also called once immediately with the current Config, so a subscriber
+// doesn't need a separate call to Current() to pick up the initial value.
+func (cw *ConfigWatcher) Subscribe(fn func(*Config)) {
+	cw.mu.Lock()
+	cw.subscribers = append(cw.subscribers, fn)
+	current := cw.current
+	cw.mu.Unlock()
+
+	fn(current)
+}
+
+func (cw *ConfigWatcher) run() {
+	for {
+		select {
+		case <-cw.done:
+			return
+		case event, ok := <-cw.watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+				continue
+			}
+			cw.reload()
+		case _, ok := <-cw.watcher.Errors:
+			if !ok {
+				return
+			}
+		}
+	}
+}
+
+// reload re-runs LoadConfig against cw.path and, if it parses and validates
+// cleanly, stores the result and fans it out to every subscriber. A reload
+// that fails validation (e.g. an operator mid-edit of the file) is dropped
+// silently rather than notifying subscribers with a half-written config -
+// the previous, still-valid Config stays in effect until a clean write
+// lands.
+func (cw *ConfigWatcher) reload() {
+	config, err := LoadConfig(cw.path)
+	if err != nil {
+		return
+	}
+
+	cw.mu.Lock()
+	cw.current = config
+	subscribers := append([]func(*Config){}, cw.subscribers...)
+	cw.mu.Unlock()
+
+	for _, fn := range subscribers {
+		fn(config)
+	}
+}
+
+// Close stops the underlying fsnotify watcher.
+func (cw *ConfigWatcher) Close() error {
+	close(cw.done)
+	return cw.watcher.Close()
+}
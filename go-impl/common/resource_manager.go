@@ -1,7 +1,6 @@
 package common
 
 import (
-	"log"
 	"runtime"
 	"syscall"
 	"time"
@@ -11,27 +10,29 @@ import (
 type ResourceManager struct {
 	MaxProcs int
 	Priority int
+	logger   *Logger
 }
 
 // NewResourceManager 新しいリソースマネージャーを作成
-func NewResourceManager() *ResourceManager {
+func NewResourceManager(logger *Logger) *ResourceManager {
 	return &ResourceManager{
 		MaxProcs: runtime.NumCPU(),
 		Priority: -10, // 高優先度
+		logger:   logger,
 	}
 }
 
 // FixResources リソースを固定化
 func (rm *ResourceManager) FixResources() error {
-	log.Println("リソース固定化を開始...")
+	rm.logger.Info("リソース固定化を開始...")
 
 	// 1. GOMAXPROCSを固定
 	runtime.GOMAXPROCS(rm.MaxProcs)
-	log.Printf("GOMAXPROCSを%dに固定", rm.MaxProcs)
+	rm.logger.Info("GOMAXPROCSを固定", "max_procs", rm.MaxProcs)
 
 	// 2. プロセス優先度を設定
 	if err := rm.setProcessPriority(); err != nil {
-		log.Printf("プロセス優先度設定エラー: %v", err)
+		rm.logger.Error("プロセス優先度設定エラー", "error", err)
 	}
 
 	// 3. メモリ使用量を最適化
@@ -39,12 +40,12 @@ func (rm *ResourceManager) FixResources() error {
 
 	// 4. ガベージコレクションを実行
 	runtime.GC()
-	log.Println("ガベージコレクションを実行")
+	rm.logger.Info("ガベージコレクションを実行")
 
 	// 5. システムリソース状態をログ出力
 	rm.logResourceStatus()
 
-	log.Println("リソース固定化完了")
+	rm.logger.Info("リソース固定化完了")
 	return nil
 }
 
@@ -57,7 +58,7 @@ func (rm *ResourceManager) setProcessPriority() error {
 		if err != nil {
 			return err
 		}
-		log.Printf("プロセス優先度を%dに設定", rm.Priority)
+		rm.logger.Info("プロセス優先度を設定", "priority", rm.Priority)
 	}
 	return nil
 }
@@ -68,13 +69,12 @@ func (rm *ResourceManager) optimizeMemory() {
 	var m runtime.MemStats
 	runtime.ReadMemStats(&m)
 
-	log.Printf("メモリ使用量: %d KB", m.Alloc/1024)
-	log.Printf("システムメモリ: %d KB", m.Sys/1024)
+	rm.logger.Info("メモリ使用量", "alloc_kb", m.Alloc/1024, "sys_kb", m.Sys/1024)
 
 	// メモリ使用量が大きい場合はガベージコレクションを実行
 	if m.Alloc > 100*1024*1024 { // 100MB以上
 		runtime.GC()
-		log.Println("メモリ使用量が大きいため、ガベージコレクションを実行")
+		rm.logger.Info("メモリ使用量が大きいため、ガベージコレクションを実行")
 	}
 }
 
@@ -83,27 +83,27 @@ func (rm *ResourceManager) logResourceStatus() {
 	var m runtime.MemStats
 	runtime.ReadMemStats(&m)
 
-	log.Println("=== リソース状態 ===")
-	log.Printf("GOMAXPROCS: %d", runtime.GOMAXPROCS(0))
-	log.Printf("Goroutine数: %d", runtime.NumGoroutine())
-	log.Printf("メモリ使用量: %d KB", m.Alloc/1024)
-	log.Printf("システムメモリ: %d KB", m.Sys/1024)
-	log.Printf("ガベージコレクション回数: %d", m.NumGC)
-	log.Println("==================")
+	rm.logger.Info("リソース状態",
+		"gomaxprocs", runtime.GOMAXPROCS(0),
+		"goroutines", runtime.NumGoroutine(),
+		"alloc_kb", m.Alloc/1024,
+		"sys_kb", m.Sys/1024,
+		"num_gc", m.NumGC,
+	)
 }
 
 // SetMaxProcs 最大プロセス数を設定
 func (rm *ResourceManager) SetMaxProcs(maxProcs int) {
 	rm.MaxProcs = maxProcs
 	runtime.GOMAXPROCS(maxProcs)
-	log.Printf("GOMAXPROCSを%dに変更", maxProcs)
+	rm.logger.Info("GOMAXPROCSを変更", "max_procs", maxProcs)
 }
 
 // SetPriority プロセス優先度を設定
 func (rm *ResourceManager) SetPriority(priority int) {
 	rm.Priority = priority
 	if err := rm.setProcessPriority(); err != nil {
-		log.Printf("プロセス優先度設定エラー: %v", err)
+		rm.logger.Error("プロセス優先度設定エラー", "error", err)
 	}
 }
 
@@ -119,7 +119,7 @@ func (rm *ResourceManager) MonitorResources(interval time.Duration) {
 
 // CleanupResources リソースクリーンアップ
 func (rm *ResourceManager) CleanupResources() {
-	log.Println("リソースクリーンアップを実行...")
+	rm.logger.Info("リソースクリーンアップを実行...")
 
 	// ガベージコレクションを実行
 	runtime.GC()
@@ -127,5 +127,5 @@ func (rm *ResourceManager) CleanupResources() {
 	// 最終的なリソース状態をログ出力
 	rm.logResourceStatus()
 
-	log.Println("リソースクリーンアップ完了")
+	rm.logger.Info("リソースクリーンアップ完了")
 }
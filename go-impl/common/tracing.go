@@ -0,0 +1,120 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// Span is one per-request timing record in the shape an OTLP/HTTP collector
+// expects of a span: enough to plot per-request latency against a trace ID
+// in a real OpenTelemetry backend, without pulling in the full
+// go.opentelemetry.io/otel SDK just to emit single, already-computed
+// durations from a benchmark loop that has no other tracing needs.
+type Span struct {
+	Name      string            `json:"name"`
+	RequestID string            `json:"request_id"`
+	StartTime time.Time         `json:"start_time"`
+	Duration  time.Duration     `json:"duration_ns"`
+	Attrs     map[string]string `json:"attributes,omitempty"`
+}
+
+// SpanExporter ships completed Spans somewhere - typically an OTLP
+// collector, but tests and offline runs can substitute any implementation.
+type SpanExporter interface {
+	Export(span Span) error
+}
+
+// OTLPSpanExporter posts each Span as its own JSON document to an OTLP/HTTP
+// collector endpoint (e.g. "http://otel-collector:4318/v1/traces"). It's
+// intentionally not the real OTLP protobuf-over-HTTP wire format - this repo
+// has no otel dependency elsewhere, and a collector configured with an OTLP
+// JSON receiver (or a small adapter in front of one) can accept this shape
+// directly, the same way client/network_control.go talks to the router over
+// plain JSON instead of pulling in a client library for it.
+type OTLPSpanExporter struct {
+	Endpoint string
+	Client   *http.Client
+}
+
+// NewOTLPSpanExporter returns an exporter posting to endpoint with a 5s
+// per-span timeout.
+func NewOTLPSpanExporter(endpoint string) *OTLPSpanExporter {
+	return &OTLPSpanExporter{
+		Endpoint: endpoint,
+		Client:   &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// Export POSTs span as JSON to e.Endpoint. A collector that's unreachable or
+// slow must never block or fail the benchmark it's instrumenting, so callers
+// should treat Export's error as log-and-continue, not a fatal condition.
+func (e *OTLPSpanExporter) Export(span Span) error {
+	body, err := json.Marshal(span)
+	if err != nil {
+		return fmt.Errorf("failed to marshal span: %v", err)
+	}
+
+	resp, err := e.Client.Post(e.Endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to export span: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("span exporter returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// AsyncSpanExporter wraps another SpanExporter and ships spans from a
+// single background goroutine, so Export itself never blocks on the
+// wrapped exporter's I/O (OTLPSpanExporter's Export does a blocking
+// http.Post with up to a 5s timeout, which would otherwise land in a
+// benchmark's per-request hot loop). If the buffer fills - the collector
+// is slower than the benchmark is generating spans - new spans are dropped
+// rather than blocking the caller, consistent with this exporter never
+// being allowed to fail or slow down the run it's instrumenting.
+type AsyncSpanExporter struct {
+	next    SpanExporter
+	spans   chan Span
+	dropped atomic.Int64
+}
+
+// NewAsyncSpanExporter starts the background export goroutine and returns
+// the wrapper. bufferSize bounds how many spans can queue before Export
+// starts dropping them.
+func NewAsyncSpanExporter(next SpanExporter, bufferSize int) *AsyncSpanExporter {
+	e := &AsyncSpanExporter{next: next, spans: make(chan Span, bufferSize)}
+	go e.run()
+	return e
+}
+
+func (e *AsyncSpanExporter) run() {
+	for span := range e.spans {
+		// Errors are swallowed here the same way callers of the sync
+		// exportSpan helper already swallow them - see client/benchmark.go.
+		_ = e.next.Export(span)
+	}
+}
+
+// Export enqueues span for the background goroutine, returning immediately.
+// It never returns an error: a full buffer drops the span (see Dropped)
+// instead of blocking or failing the caller.
+func (e *AsyncSpanExporter) Export(span Span) error {
+	select {
+	case e.spans <- span:
+	default:
+		e.dropped.Add(1)
+	}
+	return nil
+}
+
+// Dropped reports how many spans have been discarded so far because the
+// buffer was full.
+func (e *AsyncSpanExporter) Dropped() int64 {
+	return e.dropped.Load()
+}
@@ -0,0 +1,58 @@
+package hdr
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+)
+
+// percentileLadder are the percentile points a .hgrm report walks, matching
+// the de-facto HdrHistogram plotting convention closely enough to be
+// readable by the same plotting tools/spreadsheets.
+var percentileLadder = []float64{
+	0, 50, 75, 90, 95, 99, 99.9, 99.99, 99.999, 100,
+}
+
+// WritePercentileDistribution renders a .hgrm-style percentile distribution:
+// one line per percentile point giving the value at that percentile, the
+// percentile itself, the cumulative count at or below it, and
+// 1/(1-percentile) so a log-scale plot of the last column against value
+// produces the usual "HdrHistogram" tail-latency curve. valueUnitScale
+// divides recorded values before printing (e.g. pass 1e6 to report
+// nanosecond-denominated values in milliseconds).
+func (h *Histogram) WritePercentileDistribution(w io.Writer, valueUnitScale float64) error {
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintf(bw, "%12s %14s %10s %14s\n", "Value", "Percentile", "TotalCount", "1/(1-Percentile)")
+	for _, p := range percentileLadder {
+		value := float64(h.ValueAtPercentile(p)) / valueUnitScale
+		inverse := math.Inf(1)
+		if p < 100 {
+			inverse = 1 / (1 - p/100)
+		}
+		fmt.Fprintf(bw, "%12.3f %14.5f %10d %14.2f\n", value, p/100, h.countAtOrBelowPercentile(p), inverse)
+	}
+	fmt.Fprintf(bw, "#[Mean    = %.3f, StdDeviation   = %.3f]\n", h.Mean()/valueUnitScale, h.StdDev()/valueUnitScale)
+	fmt.Fprintf(bw, "#[Max     = %.3f, TotalCount     = %d]\n", float64(h.Max())/valueUnitScale, h.totalCount)
+
+	return bw.Flush()
+}
+
+// countAtOrBelowPercentile is the cumulative count ValueAtPercentile(p)
+// accumulated to, recomputed rather than threaded through ValueAtPercentile
+// since a .hgrm line needs both the value and the count that produced it.
+func (h *Histogram) countAtOrBelowPercentile(p float64) int64 {
+	if h.totalCount == 0 {
+		return 0
+	}
+	target := int64(math.Ceil(float64(h.totalCount) * p / 100))
+	var accum int64
+	for _, c := range h.counts {
+		accum += c
+		if accum >= target {
+			return accum
+		}
+	}
+	return h.totalCount
+}
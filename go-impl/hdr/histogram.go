@@ -0,0 +1,250 @@
+// Package hdr implements an HdrHistogram-style latency recorder: values are
+// bucketed by magnitude (floor(log2(v))) with a linear sub-bucket offset
+// inside each magnitude, giving a fixed number of significant figures of
+// precision with O(1) Record and a bucket count that's independent of how
+// many samples are recorded.
+package hdr
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Histogram records int64 values between LowestTrackable and
+// HighestTrackable with SignificantFigures decimal digits of precision.
+type Histogram struct {
+	lowestTrackable    int64
+	highestTrackable   int64
+	significantFigures int
+
+	unitMagnitude               int
+	subBucketHalfCountMagnitude int
+	subBucketCount              int
+	subBucketHalfCount          int
+	subBucketMask               int64
+
+	counts     []int64
+	totalCount int64
+	min        int64
+	max        int64
+}
+
+// New creates a Histogram covering [lowestTrackable, highestTrackable] with
+// the given number of significant decimal digits (typically 2-5; 3 gives
+// ~0.1% resolution at any magnitude).
+func New(lowestTrackable, highestTrackable int64, significantFigures int) *Histogram {
+	h := &Histogram{
+		lowestTrackable:    lowestTrackable,
+		highestTrackable:   highestTrackable,
+		significantFigures: significantFigures,
+		min:                math.MaxInt64,
+		max:                0,
+	}
+
+	h.unitMagnitude = int(math.Floor(math.Log2(float64(lowestTrackable))))
+
+	largestValueWithSingleUnitResolution := 2 * math.Pow10(significantFigures)
+	subBucketCountMagnitude := int(math.Ceil(math.Log2(largestValueWithSingleUnitResolution)))
+	h.subBucketHalfCountMagnitude = subBucketCountMagnitude - 1
+	if h.subBucketHalfCountMagnitude < 0 {
+		h.subBucketHalfCountMagnitude = 0
+	}
+	h.subBucketCount = 1 << uint(h.subBucketHalfCountMagnitude+1)
+	h.subBucketHalfCount = h.subBucketCount / 2
+	h.subBucketMask = int64(h.subBucketCount-1) << uint(h.unitMagnitude)
+
+	bucketsNeeded := 1
+	smallestUntrackableValue := int64(h.subBucketCount) << uint(h.unitMagnitude)
+	for smallestUntrackableValue < highestTrackable {
+		smallestUntrackableValue <<= 1
+		bucketsNeeded++
+	}
+
+	countsLen := (bucketsNeeded + 1) * h.subBucketHalfCount
+	h.counts = make([]int64, countsLen)
+
+	return h
+}
+
+// NewDuration creates a Histogram sized for recording time.Duration values in
+// nanoseconds, from lowest to highest.
+func NewDuration(lowest, highest time.Duration, significantFigures int) *Histogram {
+	return New(int64(lowest), int64(highest), significantFigures)
+}
+
+func (h *Histogram) bucketIndexOf(value int64) int {
+	pow2Ceiling := 64 - leadingZeros64(value|h.subBucketMask)
+	return pow2Ceiling - h.unitMagnitude - (h.subBucketHalfCountMagnitude + 1)
+}
+
+func (h *Histogram) subBucketIndexOf(value int64, bucketIndex int) int {
+	return int(value >> uint(bucketIndex+h.unitMagnitude))
+}
+
+func (h *Histogram) countsIndex(bucketIndex, subBucketIndex int) int {
+	bucketBaseIndex := (bucketIndex + 1) << uint(h.subBucketHalfCountMagnitude)
+	offsetInBucket := subBucketIndex - h.subBucketHalfCount
+	return bucketBaseIndex + offsetInBucket
+}
+
+func leadingZeros64(v int64) int {
+	n := 0
+	u := uint64(v)
+	for i := 63; i >= 0; i-- {
+		if u&(1<<uint(i)) != 0 {
+			break
+		}
+		n++
+	}
+	return n
+}
+
+func (h *Histogram) indexFor(value int64) int {
+	if value < 0 {
+		value = 0
+	}
+	if value > h.highestTrackable {
+		value = h.highestTrackable
+	}
+	bucketIndex := h.bucketIndexOf(value)
+	subBucketIndex := h.subBucketIndexOf(value, bucketIndex)
+	return h.countsIndex(bucketIndex, subBucketIndex)
+}
+
+// valueFromIndex recovers the (lower bound of the) value a counts index
+// represents, for reporting percentiles.
+func (h *Histogram) valueFromIndex(index int) int64 {
+	bucketIndex := index>>uint(h.subBucketHalfCountMagnitude) - 1
+	subBucketIndex := index - ((bucketIndex + 1) << uint(h.subBucketHalfCountMagnitude)) + h.subBucketHalfCount
+	if bucketIndex < 0 {
+		return int64(subBucketIndex-h.subBucketHalfCount) << uint(h.unitMagnitude)
+	}
+	return int64(subBucketIndex) << uint(bucketIndex+h.unitMagnitude)
+}
+
+// Record adds a single observation.
+func (h *Histogram) Record(value int64) {
+	h.counts[h.indexFor(value)]++
+	h.totalCount++
+	if value < h.min {
+		h.min = value
+	}
+	if value > h.max {
+		h.max = value
+	}
+}
+
+// RecordDuration is Record for a time.Duration, in nanoseconds.
+func (h *Histogram) RecordDuration(d time.Duration) {
+	h.Record(int64(d))
+}
+
+// RecordCorrectedForCoordinatedOmission records value, and if value exceeds
+// expectedInterval it back-fills synthetic samples at expectedInterval steps
+// down to expectedInterval. This is what an open-loop load generator needs:
+// without it, a request delayed behind a slow one is recorded once instead
+// of "owning" the stall it actually experienced, understating tail latency.
+func (h *Histogram) RecordCorrectedForCoordinatedOmission(value, expectedInterval int64) {
+	h.Record(value)
+	if expectedInterval <= 0 || value <= expectedInterval {
+		return
+	}
+	for missingValue := value - expectedInterval; missingValue >= expectedInterval; missingValue -= expectedInterval {
+		h.Record(missingValue)
+	}
+}
+
+// RecordDurationCorrectedForCoordinatedOmission is
+// RecordCorrectedForCoordinatedOmission for time.Duration values.
+func (h *Histogram) RecordDurationCorrectedForCoordinatedOmission(d, expectedInterval time.Duration) {
+	h.RecordCorrectedForCoordinatedOmission(int64(d), int64(expectedInterval))
+}
+
+// ValueAtPercentile returns the highest value such that at least p percent of
+// recorded observations are less than or equal to it.
+func (h *Histogram) ValueAtPercentile(p float64) int64 {
+	if h.totalCount == 0 {
+		return 0
+	}
+	if p > 100 {
+		p = 100
+	}
+	target := int64(math.Ceil(float64(h.totalCount) * p / 100))
+
+	var accum int64
+	for i, c := range h.counts {
+		accum += c
+		if accum >= target {
+			return h.valueFromIndex(i)
+		}
+	}
+	return h.max
+}
+
+// Min, Max, Mean and TotalCount report summary statistics alongside the
+// percentile distribution.
+func (h *Histogram) Min() int64 {
+	if h.totalCount == 0 {
+		return 0
+	}
+	return h.min
+}
+
+func (h *Histogram) Max() int64 { return h.max }
+
+func (h *Histogram) TotalCount() int64 { return h.totalCount }
+
+func (h *Histogram) Mean() float64 {
+	if h.totalCount == 0 {
+		return 0
+	}
+	var weighted float64
+	for i, c := range h.counts {
+		if c == 0 {
+			continue
+		}
+		weighted += float64(h.valueFromIndex(i)) * float64(c)
+	}
+	return weighted / float64(h.totalCount)
+}
+
+// StdDev returns the population standard deviation of recorded observations,
+// computed from the bucketed distribution rather than the raw samples -
+// consistent with Mean, it answers "as precise as the histogram's bucket
+// resolution allows" rather than "exact".
+func (h *Histogram) StdDev() float64 {
+	if h.totalCount == 0 {
+		return 0
+	}
+	mean := h.Mean()
+	var sumSquaredDeviation float64
+	for i, c := range h.counts {
+		if c == 0 {
+			continue
+		}
+		deviation := float64(h.valueFromIndex(i)) - mean
+		sumSquaredDeviation += deviation * deviation * float64(c)
+	}
+	return math.Sqrt(sumSquaredDeviation / float64(h.totalCount))
+}
+
+// Merge folds another histogram with an identical layout into this one.
+// Histograms built with New using the same bounds and significant figures
+// always share a layout.
+func (h *Histogram) Merge(other *Histogram) error {
+	if len(h.counts) != len(other.counts) {
+		return fmt.Errorf("hdr: cannot merge histograms with different layouts (%d buckets vs %d)", len(h.counts), len(other.counts))
+	}
+	for i, c := range other.counts {
+		h.counts[i] += c
+	}
+	h.totalCount += other.totalCount
+	if other.min < h.min {
+		h.min = other.min
+	}
+	if other.max > h.max {
+		h.max = other.max
+	}
+	return nil
+}
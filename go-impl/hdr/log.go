@@ -0,0 +1,97 @@
+package hdr
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+)
+
+// IntervalLogWriter appends one compressed, base64-encoded snapshot of a
+// Histogram's raw counts per call to Write, so a long-running benchmark can
+// be replayed afterwards to recompute any percentile over any sub-range of
+// the run instead of only the percentiles captured live.
+type IntervalLogWriter struct {
+	w *bufio.Writer
+}
+
+func NewIntervalLogWriter(w io.Writer) *IntervalLogWriter {
+	return &IntervalLogWriter{w: bufio.NewWriter(w)}
+}
+
+// Write appends one interval's histogram, tagged with the wall-clock instant
+// it was captured.
+func (lw *IntervalLogWriter) Write(tag string, capturedAt time.Time, h *Histogram) error {
+	encoded, err := encodeCounts(h)
+	if err != nil {
+		return fmt.Errorf("hdr: failed to encode interval: %v", err)
+	}
+	if _, err := fmt.Fprintf(lw.w, "%s,%d,%s\n", tag, capturedAt.UnixNano(), encoded); err != nil {
+		return fmt.Errorf("hdr: failed to write interval: %v", err)
+	}
+	return nil
+}
+
+func (lw *IntervalLogWriter) Flush() error {
+	return lw.w.Flush()
+}
+
+func encodeCounts(h *Histogram) (string, error) {
+	var raw bytes.Buffer
+	if err := binary.Write(&raw, binary.LittleEndian, int64(len(h.counts))); err != nil {
+		return "", err
+	}
+	for _, c := range h.counts {
+		if err := binary.Write(&raw, binary.LittleEndian, c); err != nil {
+			return "", err
+		}
+	}
+
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	if _, err := zw.Write(raw.Bytes()); err != nil {
+		return "", err
+	}
+	if err := zw.Close(); err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(compressed.Bytes()), nil
+}
+
+// decodeCounts reverses encodeCounts, for post-hoc analysis tools that need
+// the raw bucket counts back out of a logged interval.
+func decodeCounts(encoded string) ([]int64, error) {
+	compressed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("hdr: invalid base64 interval: %v", err)
+	}
+
+	zr, err := zlib.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, fmt.Errorf("hdr: invalid compressed interval: %v", err)
+	}
+	defer zr.Close()
+
+	raw, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("hdr: failed to decompress interval: %v", err)
+	}
+
+	reader := bytes.NewReader(raw)
+	var n int64
+	if err := binary.Read(reader, binary.LittleEndian, &n); err != nil {
+		return nil, fmt.Errorf("hdr: truncated interval header: %v", err)
+	}
+
+	counts := make([]int64, n)
+	if err := binary.Read(reader, binary.LittleEndian, &counts); err != nil {
+		return nil, fmt.Errorf("hdr: truncated interval body: %v", err)
+	}
+
+	return counts, nil
+}
@@ -0,0 +1,256 @@
+// Package loadgen drives a unit of work (one HTTP/2 or HTTP/3 request, one
+// gRPC call, ...) under closed-loop, open-loop Poisson, or ramp/step load
+// profiles, and reports both service-time and response-time distributions so
+// coordinated omission doesn't hide queueing delay under load.
+package loadgen
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Model selects how the Runner schedules work.
+type Model int
+
+const (
+	// ClosedLoop runs Concurrency workers that each issue the next request
+	// as soon as the previous one completes - simple, but it conflates
+	// service time with queueing delay and self-throttles under load.
+	ClosedLoop Model = iota
+	// OpenLoopPoisson issues requests on a precomputed Poisson arrival
+	// schedule at the target RPS, independent of how long prior requests
+	// took, so it can actually drive and reveal queueing.
+	OpenLoopPoisson
+	// Ramp runs a sequence of open-loop stages at increasing RPS, useful
+	// for finding the knee where tail latency starts to blow up.
+	Ramp
+)
+
+// Config parameterizes a single Runner invocation.
+type Config struct {
+	Model       Model
+	Concurrency int           // ClosedLoop: worker pool size. OpenLoop/Ramp: max in-flight requests.
+	Count       int           // ClosedLoop: stop after exactly this many calls. 0 means use Duration instead.
+	RPS         float64       // OpenLoopPoisson: target arrival rate.
+	Duration    time.Duration // how long to generate load, after Warmup.
+	Warmup      time.Duration // run and discard samples for this long before recording.
+	RampStages  []float64     // Ramp: target RPS for each stage, run back to back.
+	RampStep    time.Duration // Ramp: how long each stage in RampStages runs.
+}
+
+// Sample is one unit of work's timing. IntendedStart and ActualStart differ
+// only under load, once queueing delay creeps in; ServiceTime and
+// ResponseTime below are derived from the gap between them.
+type Sample struct {
+	IntendedStart time.Time
+	ActualStart   time.Time
+	Complete      time.Time
+	Err           error
+}
+
+// ServiceTime is how long the unit of work itself took, once it started.
+func (s Sample) ServiceTime() time.Duration { return s.Complete.Sub(s.ActualStart) }
+
+// ResponseTime is end-to-end latency including any queueing delay between
+// when the request was scheduled to start and when it actually did - this is
+// the number coordinated-omission-naive benchmarks under-report.
+func (s Sample) ResponseTime() time.Duration { return s.Complete.Sub(s.IntendedStart) }
+
+// Result is everything a Runner collected for one run.
+type Result struct {
+	Samples   []Sample
+	Successes int
+	Failures  int
+}
+
+// Runner issues Do repeatedly according to Config and records a Sample per
+// call. Do should perform exactly one unit of work (e.g. one HTTP request)
+// and return its error, if any.
+type Runner struct {
+	Config Config
+	Do     func(ctx context.Context) error
+	// OnSample, if set, is invoked synchronously after each sample is
+	// recorded - e.g. for progress logging.
+	OnSample func(Sample)
+}
+
+func NewRunner(cfg Config, do func(ctx context.Context) error) *Runner {
+	return &Runner{Config: cfg, Do: do}
+}
+
+// Run executes the configured load profile and returns the recorded samples.
+func (r *Runner) Run(ctx context.Context) Result {
+	if r.Config.Warmup > 0 {
+		if r.Config.Model == ClosedLoop {
+			// Count cleared so the warmup runs for Warmup and doesn't use up
+			// the measured call budget; its samples are dropped.
+			warm := Runner{Config: r.Config, Do: r.Do, OnSample: r.OnSample}
+			warm.Config.Count = 0
+			warm.runClosedLoop(ctx, r.Config.Warmup)
+		} else {
+			r.runFor(ctx, r.Config.Warmup, r.Config.effectiveRPS())
+		}
+	}
+
+	switch r.Config.Model {
+	case OpenLoopPoisson:
+		return collect(r.runFor(ctx, r.Config.Duration, r.Config.RPS))
+	case Ramp:
+		return collect(r.runRamp(ctx))
+	default:
+		return collect(r.runClosedLoop(ctx, r.Config.Duration))
+	}
+}
+
+func (c Config) effectiveRPS() float64 {
+	if c.Model == ClosedLoop || c.RPS <= 0 {
+		return 0
+	}
+	return c.RPS
+}
+
+func collect(samples []Sample) Result {
+	result := Result{Samples: samples}
+	for _, s := range samples {
+		if s.Err != nil {
+			result.Failures++
+		} else {
+			result.Successes++
+		}
+	}
+	return result
+}
+
+// runClosedLoop runs Concurrency workers, each issuing the next request as
+// soon as its previous one completes. If Config.Count is set the workers
+// split exactly that many calls between them; otherwise they run until
+// duration elapses.
+func (r *Runner) runClosedLoop(ctx context.Context, duration time.Duration) []Sample {
+	concurrency := r.Config.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	deadline := time.Now().Add(duration)
+	var remaining int32
+	if r.Config.Count > 0 {
+		remaining = int32(r.Config.Count)
+	}
+
+	var mu sync.Mutex
+	var samples []Sample
+	var wg sync.WaitGroup
+
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				if r.Config.Count > 0 {
+					if atomic.AddInt32(&remaining, -1) < 0 {
+						return
+					}
+				} else if !time.Now().Before(deadline) {
+					return
+				}
+
+				start := time.Now()
+				err := r.Do(ctx)
+				sample := Sample{IntendedStart: start, ActualStart: start, Complete: time.Now(), Err: err}
+
+				mu.Lock()
+				samples = append(samples, sample)
+				mu.Unlock()
+				if r.OnSample != nil {
+					r.OnSample(sample)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	return samples
+}
+
+// runFor runs an open-loop Poisson schedule at rps for duration, capped at
+// Concurrency in-flight requests so a stalled backend can't spawn unbounded
+// goroutines.
+func (r *Runner) runFor(ctx context.Context, duration time.Duration, rps float64) []Sample {
+	if rps <= 0 || duration <= 0 {
+		return nil
+	}
+
+	concurrency := r.Config.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	sem := make(chan struct{}, concurrency)
+
+	schedule := poissonSchedule(rps, duration)
+
+	var mu sync.Mutex
+	var samples []Sample
+	var wg sync.WaitGroup
+
+	runStart := time.Now()
+	for _, offset := range schedule {
+		intendedStart := runStart.Add(offset)
+		if wait := time.Until(intendedStart); wait > 0 {
+			time.Sleep(wait)
+		}
+
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(intendedStart time.Time) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			actualStart := time.Now()
+			err := r.Do(ctx)
+			sample := Sample{IntendedStart: intendedStart, ActualStart: actualStart, Complete: time.Now(), Err: err}
+
+			mu.Lock()
+			samples = append(samples, sample)
+			mu.Unlock()
+			if r.OnSample != nil {
+				r.OnSample(sample)
+			}
+		}(intendedStart)
+	}
+	wg.Wait()
+
+	return samples
+}
+
+// runRamp runs RampStages back to back, each for RampStep, returning the
+// concatenation of every stage's samples.
+func (r *Runner) runRamp(ctx context.Context) []Sample {
+	var all []Sample
+	for _, stageRPS := range r.Config.RampStages {
+		all = append(all, r.runFor(ctx, r.Config.RampStep, stageRPS)...)
+	}
+	return all
+}
+
+// poissonSchedule returns arrival offsets (from 0) for a Poisson process at
+// rps over duration, i.e. exponentially distributed interarrival times, so
+// requests are fired on a schedule fixed in advance rather than back-to-back
+// after each response - this is what avoids coordinated omission.
+func poissonSchedule(rps float64, duration time.Duration) []time.Duration {
+	meanInterval := time.Duration(float64(time.Second) / rps)
+
+	var offsets []time.Duration
+	for t := time.Duration(0); t < duration; {
+		interval := time.Duration(-math.Log(1-rand.Float64()) * float64(meanInterval))
+		t += interval
+		if t >= duration {
+			break
+		}
+		offsets = append(offsets, t)
+	}
+	return offsets
+}
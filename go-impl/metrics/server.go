@@ -0,0 +1,189 @@
+// Package metrics exposes a long-running benchmark's progress as Prometheus
+// metrics plus a small embedded HTML dashboard, so a sweep (1000 requests x
+// 4 delays x 2 protocols, each with stabilization sleeps) is observable
+// before it finishes rather than only afterwards. It hand-rolls the
+// exposition format instead of depending on client_golang, matching the
+// rest of this repo's preference for small, purpose-built subsystems (hdr,
+// stats, loadgen) over pulling in general-purpose libraries.
+package metrics
+
+import (
+	_ "embed"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+//go:embed dashboard.html
+var dashboardHTML []byte
+
+// latencyBuckets are the histogram boundaries for latency_seconds, spanning
+// 1ms to 10s on a roughly log scale - enough resolution to see HTTP/2 vs
+// HTTP/3 diverge without the bucket count blowing up every /metrics scrape.
+var latencyBuckets = []float64{0.001, 0.002, 0.005, 0.01, 0.02, 0.05, 0.1, 0.2, 0.5, 1, 2, 5, 10}
+
+type latencyKey struct {
+	protocol string
+	delayMs  int
+}
+
+// latencyHistogram is a fixed-bucket cumulative histogram for one
+// {protocol,delay_ms} pair, the same counting scheme Prometheus client
+// libraries use.
+type latencyHistogram struct {
+	buckets []uint64 // cumulative counts, one per latencyBuckets entry
+	sum     float64
+	count   uint64
+}
+
+// Server accumulates counters, gauges, and per-{protocol,delay} latency
+// histograms, and serves them as Prometheus text exposition on /metrics and
+// as a live dashboard on /.
+type Server struct {
+	mu            sync.Mutex
+	histograms    map[latencyKey]*latencyHistogram
+	requestsTotal map[string]uint64 // keyed by protocol
+	failuresTotal map[string]uint64
+	phase         string
+	impairment    string
+}
+
+// NewServer creates an empty Server ready to record observations.
+func NewServer() *Server {
+	return &Server{
+		histograms:    make(map[latencyKey]*latencyHistogram),
+		requestsTotal: make(map[string]uint64),
+		failuresTotal: make(map[string]uint64),
+	}
+}
+
+// ObserveLatency bumps requests_total for every completed request and, on
+// failure, failures_total as well; only a successful request's service time
+// is recorded against its protocol/delay histogram.
+func (s *Server) ObserveLatency(protocol string, delayMs int, latency time.Duration, success bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requestsTotal[protocol]++
+	if !success {
+		s.failuresTotal[protocol]++
+		return
+	}
+
+	key := latencyKey{protocol, delayMs}
+	h, ok := s.histograms[key]
+	if !ok {
+		h = &latencyHistogram{buckets: make([]uint64, len(latencyBuckets))}
+		s.histograms[key] = h
+	}
+	seconds := latency.Seconds()
+	h.sum += seconds
+	h.count++
+	for i, le := range latencyBuckets {
+		if seconds <= le {
+			h.buckets[i]++
+		}
+	}
+}
+
+// SetPhase updates the current sweep phase gauge, e.g. "stabilizing",
+// "http2", "http3", "idle".
+func (s *Server) SetPhase(phase string) {
+	s.mu.Lock()
+	s.phase = phase
+	s.mu.Unlock()
+}
+
+// SetImpairment updates the human-readable network impairment gauge label.
+func (s *Server) SetImpairment(desc string) {
+	s.mu.Lock()
+	s.impairment = desc
+	s.mu.Unlock()
+}
+
+// ListenAndServe starts the metrics/dashboard HTTP server on addr and blocks.
+// Call it in a goroutine before the sweep begins so the dashboard is up for
+// the whole run.
+func (s *Server) ListenAndServe(addr string) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", s.handleMetrics)
+	mux.HandleFunc("/", s.handleDashboard)
+	return http.ListenAndServe(addr, mux)
+}
+
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	s.writePrometheus(w)
+}
+
+func (s *Server) handleDashboard(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(dashboardHTML)
+}
+
+// writePrometheus renders every tracked metric in Prometheus text exposition
+// format (https://prometheus.io/docs/instrumenting/exposition_formats/).
+func (s *Server) writePrometheus(w io.Writer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fmt.Fprintln(w, "# HELP requests_total Total requests issued per protocol.")
+	fmt.Fprintln(w, "# TYPE requests_total counter")
+	for _, protocol := range sortedStringKeys(s.requestsTotal) {
+		fmt.Fprintf(w, "requests_total{protocol=%q} %d\n", protocol, s.requestsTotal[protocol])
+	}
+
+	fmt.Fprintln(w, "# HELP failures_total Total failed requests per protocol.")
+	fmt.Fprintln(w, "# TYPE failures_total counter")
+	for _, protocol := range sortedStringKeys(s.failuresTotal) {
+		fmt.Fprintf(w, "failures_total{protocol=%q} %d\n", protocol, s.failuresTotal[protocol])
+	}
+
+	fmt.Fprintln(w, "# HELP latency_seconds Request latency distribution per protocol and network delay.")
+	fmt.Fprintln(w, "# TYPE latency_seconds histogram")
+	for _, key := range sortedLatencyKeys(s.histograms) {
+		h := s.histograms[key]
+		for i, le := range latencyBuckets {
+			fmt.Fprintf(w, "latency_seconds_bucket{protocol=%q,delay_ms=\"%d\",le=%q} %d\n",
+				key.protocol, key.delayMs, strconv.FormatFloat(le, 'g', -1, 64), h.buckets[i])
+		}
+		fmt.Fprintf(w, "latency_seconds_bucket{protocol=%q,delay_ms=\"%d\",le=\"+Inf\"} %d\n", key.protocol, key.delayMs, h.count)
+		fmt.Fprintf(w, "latency_seconds_sum{protocol=%q,delay_ms=\"%d\"} %g\n", key.protocol, key.delayMs, h.sum)
+		fmt.Fprintf(w, "latency_seconds_count{protocol=%q,delay_ms=\"%d\"} %d\n", key.protocol, key.delayMs, h.count)
+	}
+
+	fmt.Fprintln(w, "# HELP benchmark_phase Current sweep phase (1 = active).")
+	fmt.Fprintln(w, "# TYPE benchmark_phase gauge")
+	fmt.Fprintf(w, "benchmark_phase{phase=%q} 1\n", s.phase)
+
+	fmt.Fprintln(w, "# HELP network_impairment Currently applied network impairment (1 = active).")
+	fmt.Fprintln(w, "# TYPE network_impairment gauge")
+	fmt.Fprintf(w, "network_impairment{impairment=%q} 1\n", s.impairment)
+}
+
+func sortedStringKeys(m map[string]uint64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedLatencyKeys(m map[latencyKey]*latencyHistogram) []latencyKey {
+	keys := make([]latencyKey, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].protocol != keys[j].protocol {
+			return keys[i].protocol < keys[j].protocol
+		}
+		return keys[i].delayMs < keys[j].delayMs
+	})
+	return keys
+}
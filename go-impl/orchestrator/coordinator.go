@@ -0,0 +1,90 @@
+package orchestrator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"grpc-over-http3/common"
+)
+
+// Coordinator pushes a CoordinatorConfig to every worker and collects their
+// per-request samples once the run completes.
+type Coordinator struct {
+	Workers []string
+	logger  *common.Logger
+}
+
+func NewCoordinator(workers []string) *Coordinator {
+	return &Coordinator{Workers: workers, logger: common.NewLogger("INFO")}
+}
+
+// Run pushes cfg to all workers with a shared start time so they fire their
+// probes in lockstep, then blocks until every worker has reported back.
+func (c *Coordinator) Run(cfg CoordinatorConfig, leadTime time.Duration) (*AggregatedResult, error) {
+	startAt := time.Now().Add(leadTime)
+
+	responses := make([]*RunResponse, len(c.Workers))
+	errs := make([]error, len(c.Workers))
+
+	var wg sync.WaitGroup
+	for i, worker := range c.Workers {
+		wg.Add(1)
+		go func(i int, worker string) {
+			defer wg.Done()
+			resp, err := c.dispatch(worker, RunRequest{
+				Config:   cfg,
+				StartAt:  startAt,
+				WorkerID: worker,
+			})
+			responses[i] = resp
+			errs[i] = err
+		}(i, worker)
+	}
+	wg.Wait()
+
+	result := &AggregatedResult{
+		PerWorker: make(map[string][]Sample),
+	}
+
+	for i, resp := range responses {
+		if errs[i] != nil {
+			c.logger.Error("Worker failed", "worker", c.Workers[i], "error", errs[i])
+			continue
+		}
+		result.PerWorker[resp.WorkerID] = resp.Samples
+		result.TotalSamples += len(resp.Samples)
+		result.WorkerCount++
+	}
+
+	return result, nil
+}
+
+func (c *Coordinator) dispatch(worker string, req RunRequest) (*RunResponse, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal run request: %v", err)
+	}
+
+	httpResp, err := http.Post(fmt.Sprintf("http://%s/run", worker), "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach worker %s: %v", worker, err)
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(httpResp.Body)
+		return nil, fmt.Errorf("worker %s returned status %d: %s", worker, httpResp.StatusCode, string(respBody))
+	}
+
+	var resp RunResponse
+	if err := json.NewDecoder(httpResp.Body).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("failed to decode worker %s response: %v", worker, err)
+	}
+
+	return &resp, nil
+}
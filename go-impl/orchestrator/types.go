@@ -0,0 +1,63 @@
+// Package orchestrator extends the single-box latency harness in
+// latency_benchmark.go into a coordinator/worker system, similar in spirit
+// to hperf: a coordinator pushes one test configuration to every worker and
+// they all fire at the same wall-clock instant, so results reflect a
+// realistic multi-host client fleet instead of one client box.
+package orchestrator
+
+import "time"
+
+// CoordinatorConfig is the LatencyTestConfig used today, plus the dimensions
+// that only make sense once more than one worker is involved.
+type CoordinatorConfig struct {
+	Requests   int           `json:"requests"`
+	Timeout    time.Duration `json:"timeout"`
+	Delays     []int         `json:"delays"`
+	LossRate   int           `json:"loss_rate"`
+	ServerAddr string        `json:"server_addr"`
+	HTTP2Port  int           `json:"http2_port"`
+	HTTP3Port  int           `json:"http3_port"`
+
+	ConcurrentStreams int           `json:"concurrent_streams"` // per worker
+	RequestRateCap    float64       `json:"request_rate_cap"`   // requests/sec, 0 = uncapped
+	RampUp            time.Duration `json:"ramp_up"`
+
+	// Workers are the worker endpoints the coordinator controls; each one
+	// dials ServerAddr independently once StartAt arrives.
+	Workers []string `json:"-"`
+}
+
+// RunRequest is what the coordinator POSTs to each worker's /run endpoint.
+type RunRequest struct {
+	Config   CoordinatorConfig `json:"config"`
+	StartAt  time.Time         `json:"start_at"`
+	WorkerID string            `json:"worker_id"`
+}
+
+// Sample is a single probe result. A worker sends all of its Samples to the
+// coordinator at once, in its RunResponse, after its run completes.
+type Sample struct {
+	WorkerID  string        `json:"worker_id"`
+	Timestamp time.Time     `json:"timestamp"`
+	Latency   time.Duration `json:"latency"`
+	Protocol  string        `json:"protocol"`
+	Success   bool          `json:"success"`
+}
+
+// RunResponse is what a worker returns once its run completes.
+type RunResponse struct {
+	WorkerID string   `json:"worker_id"`
+	Samples  []Sample `json:"samples"`
+}
+
+// AggregatedResult collects every worker's raw Samples keyed by worker ID,
+// plus the worker and total sample counts. Coordinator.Run leaves Protocol
+// and Delay unset and computes no latency statistics or cross-worker
+// comparison from the samples.
+type AggregatedResult struct {
+	Protocol     string              `json:"protocol"`
+	Delay        int                 `json:"delay_ms"`
+	WorkerCount  int                 `json:"worker_count"`
+	PerWorker    map[string][]Sample `json:"per_worker"`
+	TotalSamples int                 `json:"total_samples"`
+}
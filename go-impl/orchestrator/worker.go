@@ -0,0 +1,109 @@
+package orchestrator
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/quic-go/quic-go/http3"
+
+	"grpc-over-http3/common"
+)
+
+// Worker exposes a /run endpoint that the coordinator POSTs a RunRequest to.
+// Each worker probes ServerAddr independently with its own HTTP client, so
+// results reflect that worker's own network path rather than the
+// coordinator's.
+type Worker struct {
+	ID     string
+	logger *common.Logger
+}
+
+func NewWorker(id string) *Worker {
+	return &Worker{ID: id, logger: common.NewLogger("INFO")}
+}
+
+// ListenAndServe starts the worker's HTTP control server on addr.
+func (w *Worker) ListenAndServe(addr string) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/run", w.handleRun)
+	w.logger.Info("Worker listening", "worker_id", w.ID, "addr", addr)
+	return http.ListenAndServe(addr, mux)
+}
+
+func (w *Worker) handleRun(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req RunRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(rw, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if wait := time.Until(req.StartAt); wait > 0 {
+		time.Sleep(wait)
+	}
+
+	samples := w.probe(req.Config)
+
+	resp := RunResponse{WorkerID: w.ID, Samples: samples}
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(resp); err != nil {
+		w.logger.Error("Failed to encode run response", "error", err)
+	}
+}
+
+// probe fires cfg.Requests GET /health calls over both HTTP/2 and HTTP/3,
+// mirroring runHTTP2LatencyTest/runHTTP3LatencyTest in latency_benchmark.go
+// closely enough to be comparable, without importing that package main.
+// It reads only Requests, Timeout, ServerAddr, HTTP2Port and HTTP3Port from
+// cfg and issues the requests one at a time; Delays, LossRate,
+// ConcurrentStreams, RequestRateCap and RampUp are not applied.
+func (w *Worker) probe(cfg CoordinatorConfig) []Sample {
+	var samples []Sample
+	samples = append(samples, w.probeProtocol(cfg, "http2", w.http2Client(cfg), cfg.HTTP2Port, "http")...)
+	samples = append(samples, w.probeProtocol(cfg, "http3", w.http3Client(cfg), cfg.HTTP3Port, "https")...)
+	return samples
+}
+
+func (w *Worker) http2Client(cfg CoordinatorConfig) *http.Client {
+	return &http.Client{Timeout: cfg.Timeout}
+}
+
+func (w *Worker) http3Client(cfg CoordinatorConfig) *http.Client {
+	return &http.Client{
+		Transport: &http3.RoundTripper{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+		Timeout: cfg.Timeout,
+	}
+}
+
+func (w *Worker) probeProtocol(cfg CoordinatorConfig, protocol string, client *http.Client, port int, scheme string) []Sample {
+	samples := make([]Sample, 0, cfg.Requests)
+
+	for i := 0; i < cfg.Requests; i++ {
+		start := time.Now()
+		resp, err := client.Get(fmt.Sprintf("%s://%s:%d/health", scheme, cfg.ServerAddr, port))
+		sample := Sample{
+			WorkerID:  w.ID,
+			Timestamp: start,
+			Latency:   time.Since(start),
+			Protocol:  protocol,
+			Success:   err == nil,
+		}
+		if err != nil {
+			w.logger.Error("Probe request failed", "worker_id", w.ID, "protocol", protocol, "request", i+1, "error", err)
+		} else {
+			resp.Body.Close()
+		}
+		samples = append(samples, sample)
+	}
+
+	return samples
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"time"
+)
+
+// proxyCertManager mirrors server.CertManager's self-signed cert generation
+// so the proxy binary doesn't depend on the server package just for this.
+type proxyCertManager struct {
+	certPath string
+	keyPath  string
+}
+
+func (cm *proxyCertManager) loadOrGenerate() (*tls.Config, error) {
+	if _, err := os.Stat(cm.certPath); os.IsNotExist(err) {
+		if err := cm.generateSelfSigned(); err != nil {
+			return nil, fmt.Errorf("failed to generate certificate: %v", err)
+		}
+	}
+
+	cert, err := tls.LoadX509KeyPair(cm.certPath, cm.keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load key pair: %v", err)
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+func (cm *proxyCertManager) generateSelfSigned() error {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return fmt.Errorf("failed to generate private key: %v", err)
+	}
+
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject: pkix.Name{
+			Country:      []string{"JP"},
+			Organization: []string{"GRPC-Benchmark"},
+			CommonName:   "grpc-proxy.local",
+		},
+		NotBefore:   time.Now(),
+		NotAfter:    time.Now().Add(365 * 24 * time.Hour),
+		KeyUsage:    x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		DNSNames:    []string{"localhost", "grpc-proxy.local"},
+	}
+
+	certDER, err := x509.CreateCertificate(rand.Reader, &template, &template, &privateKey.PublicKey, privateKey)
+	if err != nil {
+		return fmt.Errorf("failed to create certificate: %v", err)
+	}
+
+	certOut, err := os.Create(cm.certPath)
+	if err != nil {
+		return fmt.Errorf("failed to open cert file for writing: %v", err)
+	}
+	defer certOut.Close()
+	if err := pem.Encode(certOut, &pem.Block{Type: "CERTIFICATE", Bytes: certDER}); err != nil {
+		return fmt.Errorf("failed to write cert data: %v", err)
+	}
+
+	keyOut, err := os.Create(cm.keyPath)
+	if err != nil {
+		return fmt.Errorf("failed to open key file for writing: %v", err)
+	}
+	defer keyOut.Close()
+
+	privBytes, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return fmt.Errorf("failed to marshal private key: %v", err)
+	}
+	if err := pem.Encode(keyOut, &pem.Block{Type: "PRIVATE KEY", Bytes: privBytes}); err != nil {
+		return fmt.Errorf("failed to write key data: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,40 @@
+package main
+
+// rawCodec is a pass-through gRPC codec that never touches message
+// contents, so the proxy can forward frames between the HTTP/3 front end
+// and the HTTP/2 backend without knowing the underlying protobuf schema.
+// This is the same trick used by grpc-proxy implementations: messages are
+// always exchanged as raw bytes (frame), and marshal/unmarshal become
+// no-op copies.
+type frame struct {
+	payload []byte
+}
+
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	f, ok := v.(*frame)
+	if !ok {
+		return nil, errNotAFrame
+	}
+	return f.payload, nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	f, ok := v.(*frame)
+	if !ok {
+		return errNotAFrame
+	}
+	f.payload = append(f.payload[:0], data...)
+	return nil
+}
+
+func (rawCodec) Name() string {
+	return "proxy"
+}
+
+var errNotAFrame = codecError("proxy: message is not *frame")
+
+type codecError string
+
+func (e codecError) Error() string { return string(e) }
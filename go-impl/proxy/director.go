@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// Config controls which methods the proxy forwards and how it talks to the
+// upstream HTTP/2 backend.
+type Config struct {
+	ListenPort   int
+	HTTP3Port    int
+	UpstreamAddr string
+	CertPath     string
+	KeyPath      string
+
+	// HeaderAllowList, if non-empty, is the only set of incoming metadata
+	// keys forwarded upstream. HeaderDenyList is applied after the allow
+	// list and always wins.
+	HeaderAllowList []string
+	HeaderDenyList  []string
+
+	// MethodTimeouts overrides the default per-call timeout for specific
+	// fully-qualified method names (e.g. "/echo.EchoService/Echo").
+	MethodTimeouts map[string]time.Duration
+	DefaultTimeout time.Duration
+}
+
+// director builds outgoing contexts/connections for a proxied call, applying
+// the configured header allow/deny lists and per-method timeouts.
+type director struct {
+	cfg      Config
+	upstream *grpc.ClientConn
+}
+
+func newDirector(cfg Config, upstream *grpc.ClientConn) *director {
+	return &director{cfg: cfg, upstream: upstream}
+}
+
+// Connect prepares the outgoing context and connection used for a single
+// proxied RPC.
+func (d *director) Connect(ctx context.Context, fullMethodName string) (context.Context, *grpc.ClientConn, error) {
+	outCtx := ctx
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		outCtx = metadata.NewOutgoingContext(ctx, d.filterHeaders(md))
+	}
+
+	timeout := d.cfg.DefaultTimeout
+	if t, ok := d.cfg.MethodTimeouts[fullMethodName]; ok {
+		timeout = t
+	}
+	if timeout > 0 {
+		outCtx, _ = context.WithTimeout(outCtx, timeout)
+	}
+
+	return outCtx, d.upstream, nil
+}
+
+func (d *director) filterHeaders(md metadata.MD) metadata.MD {
+	out := md.Copy()
+
+	if len(d.cfg.HeaderAllowList) > 0 {
+		allowed := make(metadata.MD, len(d.cfg.HeaderAllowList))
+		for _, key := range d.cfg.HeaderAllowList {
+			if vals := out.Get(key); len(vals) > 0 {
+				allowed.Set(key, vals...)
+			}
+		}
+		out = allowed
+	}
+
+	for _, key := range d.cfg.HeaderDenyList {
+		out.Delete(key)
+	}
+
+	return out
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"grpc-over-http3/common"
+)
+
+// HopMetrics tracks per-method latency for the proxy's upstream hop,
+// separately from whatever the client measures end-to-end, so overhead can
+// be attributed to the transport hop vs. the application.
+type HopMetrics struct {
+	mu      sync.Mutex
+	samples map[string][]time.Duration
+}
+
+func NewHopMetrics() *HopMetrics {
+	return &HopMetrics{samples: make(map[string][]time.Duration)}
+}
+
+func (m *HopMetrics) Record(method string, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.samples[method] = append(m.samples[method], d)
+}
+
+func (m *HopMetrics) Snapshot() map[string][]time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make(map[string][]time.Duration, len(m.samples))
+	for k, v := range m.samples {
+		out[k] = append([]time.Duration(nil), v...)
+	}
+	return out
+}
+
+// ProxyHandler is registered as the grpc.Server's UnknownServiceHandler, so
+// every method the proxy doesn't know about at compile time is still
+// forwarded: it opens a matching client stream against the upstream,
+// preserves framing/metadata/trailers, and pumps frames in both directions
+// until one side closes.
+type ProxyHandler struct {
+	director *director
+	metrics  *HopMetrics
+	logger   *common.Logger
+}
+
+func NewProxyHandler(director *director, metrics *HopMetrics, logger *common.Logger) grpc.StreamHandler {
+	h := &ProxyHandler{director: director, metrics: metrics, logger: logger}
+	return h.handle
+}
+
+func (h *ProxyHandler) handle(srv interface{}, serverStream grpc.ServerStream) error {
+	method, ok := grpc.MethodFromServerStream(serverStream)
+	if !ok {
+		return errNoMethod
+	}
+
+	start := time.Now()
+
+	outCtx, upstream, err := h.director.Connect(serverStream.Context(), method)
+	if err != nil {
+		return err
+	}
+
+	clientStream, err := grpc.NewClientStream(outCtx, &grpc.StreamDesc{
+		ServerStreams: true,
+		ClientStreams: true,
+	}, upstream, method)
+	if err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	errCh := make(chan error, 2)
+
+	// client -> upstream
+	go func() {
+		defer wg.Done()
+		for {
+			f := &frame{}
+			if err := serverStream.RecvMsg(f); err != nil {
+				if err == io.EOF {
+					errCh <- clientStream.CloseSend()
+				} else {
+					errCh <- err
+				}
+				return
+			}
+			if err := clientStream.SendMsg(f); err != nil {
+				errCh <- err
+				return
+			}
+		}
+	}()
+
+	// upstream -> client
+	go func() {
+		defer wg.Done()
+		headerSent := false
+		for {
+			f := &frame{}
+			err := clientStream.RecvMsg(f)
+			if err == io.EOF {
+				errCh <- nil
+				return
+			}
+			if err != nil {
+				errCh <- err
+				return
+			}
+			if !headerSent {
+				if header, err := clientStream.Header(); err == nil {
+					serverStream.SetHeader(header)
+				}
+				headerSent = true
+			}
+			if err := serverStream.SendMsg(f); err != nil {
+				errCh <- err
+				return
+			}
+		}
+	}()
+
+	wg.Wait()
+	close(errCh)
+
+	var firstErr error
+	for err := range errCh {
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	serverStream.SetTrailer(clientStream.Trailer())
+	h.metrics.Record(method, time.Since(start))
+
+	return firstErr
+}
+
+var errNoMethod = metadataError("proxy: could not determine method from server stream")
+
+type metadataError string
+
+func (e metadataError) Error() string { return string(e) }
@@ -0,0 +1,92 @@
+// Command proxy is a gRPC reverse proxy that terminates HTTP/3 (QUIC) on the
+// front end and forwards every call to a plain HTTP/2 gRPC backend over
+// grpc.Dial. It exists to measure transport overhead independently of
+// application logic: the backend never has to know it is being reached over
+// QUIC.
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/quic-go/quic-go/http3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"grpc-over-http3/common"
+)
+
+func loadConfig() Config {
+	cfg := Config{
+		ListenPort:     443,
+		HTTP3Port:      4433,
+		UpstreamAddr:   "127.0.0.1:50051",
+		CertPath:       "/certs/server.crt",
+		KeyPath:        "/certs/server.key",
+		DefaultTimeout: 30 * time.Second,
+		MethodTimeouts: map[string]time.Duration{},
+	}
+
+	if addr := os.Getenv("PROXY_UPSTREAM_ADDR"); addr != "" {
+		cfg.UpstreamAddr = addr
+	}
+	if cert := os.Getenv("PROXY_CERT_PATH"); cert != "" {
+		cfg.CertPath = cert
+	}
+	if key := os.Getenv("PROXY_KEY_PATH"); key != "" {
+		cfg.KeyPath = key
+	}
+
+	return cfg
+}
+
+func main() {
+	logger := common.NewLogger("INFO")
+	logger.Info("Starting gRPC-over-HTTP/3 reverse proxy")
+
+	cfg := loadConfig()
+
+	certDir := filepath.Dir(cfg.CertPath)
+	if err := os.MkdirAll(certDir, 0755); err != nil {
+		log.Fatalf("Failed to create cert directory: %v", err)
+	}
+
+	certManager := &proxyCertManager{certPath: cfg.CertPath, keyPath: cfg.KeyPath}
+	tlsConfig, err := certManager.loadOrGenerate()
+	if err != nil {
+		log.Fatalf("Failed to prepare TLS config: %v", err)
+	}
+	tlsConfig.NextProtos = []string{"h3", "h2"}
+
+	upstream, err := grpc.Dial(cfg.UpstreamAddr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(rawCodec{})),
+	)
+	if err != nil {
+		log.Fatalf("Failed to dial upstream %s: %v", cfg.UpstreamAddr, err)
+	}
+	logger.Info("Dialed upstream HTTP/2 backend", "addr", cfg.UpstreamAddr)
+
+	metrics := NewHopMetrics()
+	director := newDirector(cfg, upstream)
+	proxyHandler := NewProxyHandler(director, metrics, logger)
+
+	grpcServer := grpc.NewServer(
+		grpc.ForceServerCodec(rawCodec{}),
+		grpc.UnknownServiceHandler(proxyHandler),
+	)
+
+	http3Server := &http3.Server{
+		Addr:      fmt.Sprintf(":%d", cfg.HTTP3Port),
+		Handler:   grpcServer,
+		TLSConfig: tlsConfig,
+	}
+
+	logger.Info("Starting HTTP/3 proxy listener", "port", cfg.HTTP3Port, "upstream", cfg.UpstreamAddr)
+	if err := http3Server.ListenAndServe(); err != nil {
+		log.Fatalf("HTTP/3 proxy server failed: %v", err)
+	}
+}
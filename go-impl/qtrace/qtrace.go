@@ -0,0 +1,162 @@
+// Package qtrace wires quic-go's qlog tracer into an HTTP/3 RoundTripper so a
+// benchmark run can explain *why* a connection was fast or slow, not just how
+// long it took. Every QUIC connection still gets its own qlog file under the
+// configured directory for deep, offline inspection, but the summary fields
+// a benchmark actually wants (handshake RTT, loss, PTOs, congestion window,
+// ...) are captured live from the same tracer callbacks qlog consumes,
+// rather than re-parsed out of the qlog JSON after the run.
+package qtrace
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/logging"
+	"github.com/quic-go/quic-go/qlog"
+)
+
+// ConnStats summarizes one QUIC connection's handshake and runtime behavior.
+type ConnStats struct {
+	HandshakeRTT      time.Duration
+	ZeroRTTAccepted   bool
+	PTOCount          int
+	PacketsLost       int
+	PacketsReordered  int
+	CongestionWindow  uint64
+	CongestionControl string
+	AckDelay          time.Duration
+}
+
+// Collector accumulates ConnStats across every connection a RoundTripper
+// opens during a benchmark run, keyed by quic-go's connection ID. A
+// benchmark run opens at most a handful of HTTP/3 connections (one per
+// client, occasionally more after a connection migration), so a
+// mutex-guarded map is simpler than anything lock-free.
+type Collector struct {
+	logDir string
+
+	mu        sync.Mutex
+	stats     map[string]*ConnStats
+	lastSeqNo string
+	order     []string // connection IDs in the order their tracers were created
+}
+
+// NewCollector creates a Collector that writes one qlog file per connection
+// into logDir, creating logDir if needed.
+func NewCollector(logDir string) (*Collector, error) {
+	if err := os.MkdirAll(logDir, 0755); err != nil {
+		return nil, fmt.Errorf("qtrace: failed to create log directory: %v", err)
+	}
+	return &Collector{logDir: logDir, stats: make(map[string]*ConnStats)}, nil
+}
+
+// Tracer is a quic.Config.Tracer suitable for http3.RoundTripper.QUICConfig.
+// Each call opens a new qlog file named after the connection ID and
+// multiplexes the official qlog tracer with one that feeds c.stats, so
+// tracing costs one pass over the event stream instead of two.
+func (c *Collector) Tracer(ctx context.Context, p logging.Perspective, connID quic.ConnectionID) *logging.ConnectionTracer {
+	stats := &ConnStats{CongestionControl: "reno"}
+	c.mu.Lock()
+	c.stats[connID.String()] = stats
+	c.lastSeqNo = connID.String()
+	c.order = append(c.order, connID.String())
+	c.mu.Unlock()
+
+	metricsTracer := &logging.ConnectionTracer{
+		UpdatedMetrics: func(rttStats *logging.RTTStats, cwnd, bytesInFlight logging.ByteCount, packetsInFlight int) {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			if stats.HandshakeRTT == 0 {
+				stats.HandshakeRTT = rttStats.SmoothedRTT()
+			}
+			stats.CongestionWindow = uint64(cwnd)
+		},
+		UpdatedPTOCount: func(value uint32) {
+			c.mu.Lock()
+			stats.PTOCount = int(value)
+			c.mu.Unlock()
+		},
+		LostPacket: func(level logging.EncryptionLevel, pn logging.PacketNumber, reason logging.PacketLossReason) {
+			c.mu.Lock()
+			stats.PacketsLost++
+			if reason == logging.PacketLossReorderingThreshold {
+				stats.PacketsReordered++
+			}
+			c.mu.Unlock()
+		},
+		UpdatedCongestionState: func(state logging.CongestionState) {
+			c.mu.Lock()
+			// logging.CongestionState has no String method, unlike most of
+			// this package's other logging types, so format it numerically
+			// rather than guess at a label for each of its values.
+			stats.CongestionControl = fmt.Sprintf("%d", state)
+			c.mu.Unlock()
+		},
+		ReceivedShortHeaderPacket: func(hdr *logging.ShortHeader, size logging.ByteCount, ecn logging.ECN, frames []logging.Frame) {
+			for _, f := range frames {
+				if ack, ok := f.(*logging.AckFrame); ok {
+					c.mu.Lock()
+					stats.AckDelay = ack.DelayTime
+					c.mu.Unlock()
+					break
+				}
+			}
+		},
+	}
+
+	qlogFile, err := os.Create(filepath.Join(c.logDir, fmt.Sprintf("%s_%s.qlog", p, connID)))
+	if err != nil {
+		// A qlog file we can't create shouldn't take the benchmark down -
+		// fall back to metrics-only collection.
+		return metricsTracer
+	}
+	qlogTracer := qlog.NewConnectionTracer(qlogFile, p, connID)
+	return logging.NewMultiplexedConnectionTracer(qlogTracer, metricsTracer)
+}
+
+// NoteZeroRTT records whether the most recently opened connection resumed
+// via 0-RTT. The tracer callbacks above don't see this directly, so
+// latency_benchmark.go calls this after inspecting the response's
+// connection state.
+func (c *Collector) NoteZeroRTT(accepted bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if stats, ok := c.stats[c.lastSeqNo]; ok {
+		stats.ZeroRTTAccepted = accepted
+	}
+}
+
+// Aggregate folds every connection opened so far into one ConnStats: the
+// handshake RTT, congestion controller, 0-RTT outcome, ACK delay and
+// congestion window of the first connection opened, with loss/PTO/reorder
+// counts summed across connections. Every connection dialed with this
+// Collector's Tracer is included, so the sums also cover short-lived
+// connections a run opens for one-off measurements (e.g. handshake timing)
+// next to its main connection, as well as any reconnect.
+func (c *Collector) Aggregate() ConnStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var agg ConnStats
+	first := true
+	for _, id := range c.order {
+		s := c.stats[id]
+		if first {
+			agg.HandshakeRTT = s.HandshakeRTT
+			agg.CongestionControl = s.CongestionControl
+			agg.ZeroRTTAccepted = s.ZeroRTTAccepted
+			agg.AckDelay = s.AckDelay
+			agg.CongestionWindow = s.CongestionWindow
+			first = false
+		}
+		agg.PTOCount += s.PTOCount
+		agg.PacketsLost += s.PacketsLost
+		agg.PacketsReordered += s.PacketsReordered
+	}
+	return agg
+}
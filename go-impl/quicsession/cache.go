@@ -0,0 +1,112 @@
+// Package quicsession persists TLS session tickets to disk so that QUIC
+// 0-RTT resumption survives across separate invocations of the latency
+// benchmark, not just across connections within one run. tls.ClientSessionCache
+// implementations that ship with the standard library are in-memory only,
+// which is fine for crypto/tls's usual case (one long-lived process) but
+// useless for a benchmark that measures "first handshake this run" vs
+// "resumed from a ticket obtained in an earlier run".
+package quicsession
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// ticketEntry is the on-disk shape of one session's resumption material:
+// crypto/tls splits a TLS 1.3 session into the opaque ticket bytes the
+// server issued and the client-side SessionState describing how to use it,
+// so both have to round-trip through the file for Get to reconstruct a
+// usable *tls.ClientSessionState.
+type ticketEntry struct {
+	Ticket []byte `json:"ticket"`
+	State  []byte `json:"state"`
+}
+
+// FileCache is a tls.ClientSessionCache backed by an in-memory map, flushed
+// to a JSON file on every Put so the most recent ticket for each session key
+// survives process exit. It needs Go 1.21+, where crypto/tls exposes
+// ClientSessionState.ResumptionState, SessionState.Bytes, ParseSessionState
+// and NewResumptionState for serializing ticket material outside the process.
+type FileCache struct {
+	path string
+
+	mu      sync.Mutex
+	entries map[string]ticketEntry
+}
+
+// NewFileCache loads any previously persisted tickets from path (ignoring a
+// missing or corrupt file - a cold cache just means the first dial of this
+// run does a full handshake instead of 0-RTT) and returns a cache that
+// persists every subsequent Put back to it.
+func NewFileCache(path string) *FileCache {
+	c := &FileCache{path: path, entries: make(map[string]ticketEntry)}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return c
+	}
+	var entries map[string]ticketEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return c
+	}
+	c.entries = entries
+	return c
+}
+
+// Get implements tls.ClientSessionCache.
+func (c *FileCache) Get(sessionKey string) (*tls.ClientSessionState, bool) {
+	c.mu.Lock()
+	entry, ok := c.entries[sessionKey]
+	c.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+
+	state, err := tls.ParseSessionState(entry.State)
+	if err != nil {
+		return nil, false
+	}
+	session, err := tls.NewResumptionState(entry.Ticket, state)
+	if err != nil {
+		return nil, false
+	}
+	return session, true
+}
+
+// Put implements tls.ClientSessionCache. A nil cs (crypto/tls's signal to
+// evict an entry) removes the on-disk ticket for sessionKey too.
+func (c *FileCache) Put(sessionKey string, cs *tls.ClientSessionState) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if cs == nil {
+		delete(c.entries, sessionKey)
+	} else {
+		ticket, state, err := cs.ResumptionState()
+		if err != nil {
+			return
+		}
+		raw, err := state.Bytes()
+		if err != nil {
+			return
+		}
+		c.entries[sessionKey] = ticketEntry{Ticket: ticket, State: raw}
+	}
+
+	if err := c.flushLocked(); err != nil {
+		// Best-effort: a failed flush just means the next process won't get
+		// 0-RTT on its first dial, not a broken benchmark run.
+		fmt.Fprintf(os.Stderr, "quicsession: failed to persist ticket cache: %v\n", err)
+	}
+}
+
+func (c *FileCache) flushLocked() error {
+	data, err := json.Marshal(c.entries)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(c.path, data, 0600)
+}
@@ -1,110 +1,406 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
-	"log"
 	"os/exec"
 	"strconv"
+
+	"grpc-over-http3/common"
+)
+
+// Distribution is the jitter distribution netem applies around DelayMs.
+type Distribution string
+
+const (
+	DistributionNone   Distribution = ""
+	DistributionNormal Distribution = "normal"
+	DistributionPareto Distribution = "pareto"
+)
+
+// Impairment is one point in the netem-style impairment matrix: every knob
+// `tc qdisc ... netem` exposes that's relevant to comparing HTTP/2 and
+// HTTP/3 under adverse conditions. LossCorrelation approximates
+// Gilbert-Elliott bursty loss via netem's simple loss-correlation model
+// (`loss PERCENT CORRELATION`), which is close enough for a synthetic sweep
+// without needing the full `loss gemodel` parameterization.
+type Impairment struct {
+	DelayMs         int          `json:"delay_ms" yaml:"delay_ms"`
+	JitterMs        int          `json:"jitter_ms" yaml:"jitter_ms"`
+	JitterDist      Distribution `json:"jitter_distribution" yaml:"jitter_distribution"`
+	LossPct         float64      `json:"loss_pct" yaml:"loss_pct"`
+	LossCorrelation float64      `json:"loss_correlation" yaml:"loss_correlation"`
+	DuplicatePct    float64      `json:"duplicate_pct" yaml:"duplicate_pct"`
+	ReorderPct      float64      `json:"reorder_pct" yaml:"reorder_pct"`
+	CorruptPct      float64      `json:"corrupt_pct" yaml:"corrupt_pct"`
+	BandwidthKbps   int          `json:"bandwidth_kbps" yaml:"bandwidth_kbps"`
+	QueueLimit      int          `json:"queue_limit" yaml:"queue_limit"`
+
+	// BurstBytes/LatencyMs size the tbf qdisc layered under the netem qdisc
+	// when BandwidthKbps is set: netem's own `rate` option is a crude
+	// token bucket with no control over burst size or queueing latency,
+	// which matters for QUIC's congestion control and loss recovery. Zero
+	// values fall back to tbfDefaultBurstBytes/tbfDefaultLatencyMs.
+	BurstBytes int `json:"burst_bytes" yaml:"burst_bytes"`
+	LatencyMs  int `json:"tbf_latency_ms" yaml:"tbf_latency_ms"`
+}
+
+// tbfDefaultBurstBytes/tbfDefaultLatencyMs are applied when BandwidthKbps is
+// set but BurstBytes/LatencyMs aren't - a 32KB burst and 50ms of queueing
+// latency are reasonable defaults for the access-link speeds this benchmark
+// simulates (sub-Gbit).
+const (
+	tbfDefaultBurstBytes = 32 * 1024
+	tbfDefaultLatencyMs  = 50
 )
 
+// Validate range-checks every field against what `tc netem`/`tbf` accept, so
+// a malformed /network/config request fails with a clear 400 instead of a tc
+// invocation failing deep inside Apply.
+func (imp Impairment) Validate() error {
+	switch {
+	case imp.DelayMs < 0:
+		return fmt.Errorf("delay_ms must be >= 0, got %d", imp.DelayMs)
+	case imp.JitterMs < 0:
+		return fmt.Errorf("jitter_ms must be >= 0, got %d", imp.JitterMs)
+	case imp.JitterDist != DistributionNone && imp.JitterDist != DistributionNormal && imp.JitterDist != DistributionPareto:
+		return fmt.Errorf("jitter_distribution must be one of %q, %q, %q, got %q", DistributionNone, DistributionNormal, DistributionPareto, imp.JitterDist)
+	case imp.LossPct < 0 || imp.LossPct > 100:
+		return fmt.Errorf("loss_pct must be in [0,100], got %v", imp.LossPct)
+	case imp.LossCorrelation < 0 || imp.LossCorrelation > 100:
+		return fmt.Errorf("loss_correlation must be in [0,100], got %v", imp.LossCorrelation)
+	case imp.DuplicatePct < 0 || imp.DuplicatePct > 100:
+		return fmt.Errorf("duplicate_pct must be in [0,100], got %v", imp.DuplicatePct)
+	case imp.ReorderPct < 0 || imp.ReorderPct > 100:
+		return fmt.Errorf("reorder_pct must be in [0,100], got %v", imp.ReorderPct)
+	case imp.CorruptPct < 0 || imp.CorruptPct > 100:
+		return fmt.Errorf("corrupt_pct must be in [0,100], got %v", imp.CorruptPct)
+	case imp.BandwidthKbps < 0:
+		return fmt.Errorf("bandwidth_kbps must be >= 0, got %d", imp.BandwidthKbps)
+	case imp.QueueLimit < 0:
+		return fmt.Errorf("queue_limit must be >= 0, got %d", imp.QueueLimit)
+	case imp.BurstBytes < 0:
+		return fmt.Errorf("burst_bytes must be >= 0, got %d", imp.BurstBytes)
+	case imp.LatencyMs < 0:
+		return fmt.Errorf("tbf_latency_ms must be >= 0, got %d", imp.LatencyMs)
+	}
+	return nil
+}
+
+// NetworkEmulation drives tc/netem on eth0 (egress/"up") and, when
+// Asymmetric is set, on an ifb-redirected ingress ("down") so upload and
+// download can be impaired differently - real access links rarely have
+// symmetric characteristics.
 type NetworkEmulation struct {
-	Delay     int // ms
-	Loss      int // percentage
-	Bandwidth int // Mbps (0 = unlimited)
+	Up         Impairment
+	Down       Impairment
+	Asymmetric bool
+
+	// Logger receives every Apply/Clear/GetStatus diagnostic that used to go
+	// to the stdlib log package, so it's correlated with the rest of the
+	// router's structured output. A zero-value NetworkEmulation falls back
+	// to a plain INFO logger (see ne.log) rather than requiring every
+	// construction site to set it.
+	Logger *common.Logger
 }
 
+// log returns ne.Logger, or a default one if it wasn't set - keeps the
+// zero-value NetworkEmulation{} usable, matching the rest of this struct's
+// fields, which all have sensible zero values too.
+func (ne *NetworkEmulation) log() *common.Logger {
+	if ne.Logger != nil {
+		return ne.Logger
+	}
+	return common.NewLogger("INFO")
+}
+
+const ifbDevice = "ifb0"
+
+// Apply tears down any existing rules and applies Up (and, if Asymmetric,
+// Down via ifb0) as netem qdiscs.
 func (ne *NetworkEmulation) Apply() error {
-	// 既存のルールをクリア
 	if err := ne.Clear(); err != nil {
-		log.Printf("Warning: Failed to clear existing rules: %v", err)
+		ne.log().Warn("Failed to clear existing rules", "error", err)
 	}
 
-	// パラメータを構築
-	var args []string
-	args = append(args, "tc", "qdisc", "add", "dev", "eth0", "root", "netem")
+	if err := applyImpairment("eth0", ne.Up); err != nil {
+		return fmt.Errorf("failed to apply upstream (egress) impairment: %v", err)
+	}
+	ne.log().Info("Applied upstream impairment", "impairment", fmt.Sprintf("%+v", ne.Up))
 
-	// 遅延設定（0msでも設定）
-	if ne.Delay >= 0 {
-		args = append(args, "delay", strconv.Itoa(ne.Delay)+"ms")
+	if ne.Asymmetric {
+		if err := ne.applyDownstream(); err != nil {
+			return fmt.Errorf("failed to apply downstream (ingress) impairment: %v", err)
+		}
+		ne.log().Info("Applied downstream impairment", "impairment", fmt.Sprintf("%+v", ne.Down))
 	}
 
-	// 損失設定（0%の場合は設定しない）
-	if ne.Loss > 0 {
-		args = append(args, "loss", strconv.Itoa(ne.Loss)+"%")
+	return nil
+}
+
+// applyDownstream redirects ingress traffic on eth0 to ifb0 so a netem qdisc
+// can be attached to it - ingress queueing discs can't run netem directly.
+func (ne *NetworkEmulation) applyDownstream() error {
+	if err := runTC([]string{"ip", "link", "add", ifbDevice, "type", "ifb"}); err != nil {
+		ne.log().Debug("ifb device may already exist", "error", err)
+	}
+	if err := runTC([]string{"ip", "link", "set", "dev", ifbDevice, "up"}); err != nil {
+		return err
 	}
+	if err := runTC([]string{"tc", "qdisc", "add", "dev", "eth0", "ingress"}); err != nil {
+		ne.log().Debug("ingress qdisc may already exist", "error", err)
+	}
+	if err := runTC([]string{
+		"tc", "filter", "add", "dev", "eth0", "parent", "ffff:",
+		"protocol", "ip", "u32", "match", "u32", "0", "0",
+		"action", "mirred", "egress", "redirect", "dev", ifbDevice,
+	}); err != nil {
+		return err
+	}
+	return applyImpairment(ifbDevice, ne.Down)
+}
 
-	// 帯域制限設定（0の場合は設定しない）
-	if ne.Bandwidth > 0 {
-		args = append(args, "rate", strconv.Itoa(ne.Bandwidth)+"mbit")
+// applyImpairment renders imp as a `tc qdisc replace ... root handle 1:
+// netem ...` command and, if a bandwidth cap is set, layers a `tbf` qdisc
+// under it as parent 1: handle 10: - netem's own `rate` option is too crude
+// a token bucket to see realistic QUIC congestion-control behavior under a
+// cap, so bandwidth shaping is done by tbf instead.
+func applyImpairment(dev string, imp Impairment) error {
+	if err := runTC(buildNetemArgs(dev, imp)); err != nil {
+		return err
+	}
+	if imp.BandwidthKbps > 0 {
+		if err := runTC(buildTbfArgs(dev, imp)); err != nil {
+			return fmt.Errorf("failed to apply bandwidth cap: %v", err)
+		}
 	}
+	return nil
+}
 
-	// 全てのパラメータが0の場合はnoqueueを使用
-	if ne.Delay == 0 && ne.Loss == 0 && ne.Bandwidth == 0 {
-		args = []string{"tc", "qdisc", "add", "dev", "eth0", "root", "noqueue"}
+// buildNetemArgs renders an Impairment as a `tc qdisc replace ... netem`
+// command under handle 1:, so a tbf qdisc can optionally attach as its
+// child. An Impairment with every field at its zero value renders as
+// noqueue, matching the no-impairment baseline case. `replace` instead of
+// `add` makes this idempotent against a qdisc left over from a prior run
+// that Clear() didn't remove (e.g. if the process crashed mid-sweep).
+func buildNetemArgs(dev string, imp Impairment) []string {
+	delayMs := imp.DelayMs
+	if imp.ReorderPct > 0 && delayMs == 0 {
+		// Reordering is only observable relative to a base delay.
+		delayMs = 10
 	}
 
+	var netemArgs []string
+
+	if delayMs > 0 {
+		netemArgs = append(netemArgs, "delay", strconv.Itoa(delayMs)+"ms")
+		if imp.JitterMs > 0 {
+			netemArgs = append(netemArgs, strconv.Itoa(imp.JitterMs)+"ms")
+			if imp.JitterDist != DistributionNone {
+				netemArgs = append(netemArgs, "distribution", string(imp.JitterDist))
+			}
+		}
+	}
+	if imp.LossPct > 0 {
+		netemArgs = append(netemArgs, "loss", pctString(imp.LossPct))
+		if imp.LossCorrelation > 0 {
+			netemArgs = append(netemArgs, pctString(imp.LossCorrelation))
+		}
+	}
+	if imp.DuplicatePct > 0 {
+		netemArgs = append(netemArgs, "duplicate", pctString(imp.DuplicatePct))
+	}
+	if imp.ReorderPct > 0 {
+		netemArgs = append(netemArgs, "reorder", pctString(imp.ReorderPct), "50%")
+	}
+	if imp.CorruptPct > 0 {
+		netemArgs = append(netemArgs, "corrupt", pctString(imp.CorruptPct))
+	}
+	if imp.QueueLimit > 0 {
+		netemArgs = append(netemArgs, "limit", strconv.Itoa(imp.QueueLimit))
+	}
+
+	if len(netemArgs) == 0 && imp.BandwidthKbps <= 0 {
+		return []string{"tc", "qdisc", "replace", "dev", dev, "root", "noqueue"}
+	}
+
+	args := []string{"tc", "qdisc", "replace", "dev", dev, "root", "handle", "1:", "netem"}
+	return append(args, netemArgs...)
+}
+
+// buildTbfArgs renders imp's bandwidth cap as a `tc qdisc ... tbf` command
+// attached under the netem qdisc's handle 1:.
+func buildTbfArgs(dev string, imp Impairment) []string {
+	burst := imp.BurstBytes
+	if burst <= 0 {
+		burst = tbfDefaultBurstBytes
+	}
+	latency := imp.LatencyMs
+	if latency <= 0 {
+		latency = tbfDefaultLatencyMs
+	}
+
+	return []string{
+		"tc", "qdisc", "replace", "dev", dev, "parent", "1:", "handle", "10:", "tbf",
+		"rate", strconv.Itoa(imp.BandwidthKbps) + "kbit",
+		"burst", strconv.Itoa(burst),
+		"latency", strconv.Itoa(latency) + "ms",
+	}
+}
+
+func pctString(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64) + "%"
+}
+
+func runTC(args []string) error {
 	cmd := exec.Command(args[0], args[1:]...)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
-		return fmt.Errorf("failed to apply network emulation: %v, output: %s", err, string(output))
+		return fmt.Errorf("%s: %v, output: %s", args[0], err, string(output))
 	}
-
-	log.Printf("Applied network emulation: delay=%dms, loss=%d%%, bandwidth=%dMbps", ne.Delay, ne.Loss, ne.Bandwidth)
 	return nil
 }
 
+// Clear removes both the egress qdisc and, if present, the ifb-redirected
+// ingress setup.
 func (ne *NetworkEmulation) Clear() error {
-	// 既存のルールを削除
-	cmd := exec.Command("tc", "qdisc", "del", "dev", "eth0", "root")
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		// ルールが存在しない場合は正常
-		log.Printf("No existing rules to clear: %s", string(output))
-		return nil
+	if output, err := exec.Command("tc", "qdisc", "del", "dev", "eth0", "root").CombinedOutput(); err != nil {
+		ne.log().Debug("No existing egress rules to clear", "output", string(output))
+	}
+	if output, err := exec.Command("tc", "qdisc", "del", "dev", "eth0", "ingress").CombinedOutput(); err != nil {
+		ne.log().Debug("No existing ingress rules to clear", "output", string(output))
+	}
+	if output, err := exec.Command("tc", "qdisc", "del", "dev", ifbDevice, "root").CombinedOutput(); err != nil {
+		ne.log().Debug("No existing ifb rules to clear", "output", string(output))
 	}
 
-	log.Println("Cleared existing network emulation rules")
+	ne.log().Info("Cleared existing network emulation rules")
 	return nil
 }
 
-func (ne *NetworkEmulation) GetStatus() (int, int, int, error) {
-	// 現在のネットワーク設定を取得
-	cmd := exec.Command("tc", "qdisc", "show", "dev", "eth0")
-	output, err := cmd.CombinedOutput()
+// GetStatus reports the impairment actually applied to eth0 (and ifb0, if
+// Asymmetric), parsed live from `tc -j qdisc show` instead of echoing back
+// the last /network/config request - this is what lets callers notice a
+// qdisc that failed to apply, or one left over from a different process.
+// JitterDist and LatencyMs aren't parsed from tc's JSON output and always
+// report the last-requested value; QueueLimit reports the last-requested
+// value unless tc shows a netem limit above zero.
+func (ne *NetworkEmulation) GetStatus() (Impairment, Impairment, bool, error) {
+	up, err := queryImpairment("eth0", ne.Up)
 	if err != nil {
-		return 0, 0, 0, fmt.Errorf("failed to get network status: %v", err)
+		return Impairment{}, Impairment{}, false, fmt.Errorf("failed to query upstream qdisc state: %v", err)
+	}
+
+	if !ne.Asymmetric {
+		return up, Impairment{}, false, nil
 	}
 
-	// 出力から遅延、損失、帯域を解析
-	// 実際の実装では、より詳細な解析が必要
-	log.Printf("Current network status: %s", string(output))
-	return ne.Delay, ne.Loss, ne.Bandwidth, nil
+	down, err := queryImpairment(ifbDevice, ne.Down)
+	if err != nil {
+		return Impairment{}, Impairment{}, false, fmt.Errorf("failed to query downstream qdisc state: %v", err)
+	}
+	return up, down, true, nil
 }
 
-func (ne *NetworkEmulation) SetDelay(delay int) error {
-	ne.Delay = delay
-	return ne.Apply()
+// tcQdisc mirrors the subset of `tc -j qdisc show`'s output this package
+// cares about. The JSON shape is iproute2's, not a stable API - unknown
+// fields are ignored, and a field missing from the output decodes as zero
+// (queryImpairment takes only JitterDist, LatencyMs and QueueLimit from the
+// fallback it was given).
+type tcQdisc struct {
+	Kind    string `json:"kind"`
+	Handle  string `json:"handle"`
+	Options struct {
+		Limit int `json:"limit"`
+		Delay struct {
+			Delay       float64 `json:"delay"`
+			Jitter      float64 `json:"jitter"`
+			Correlation float64 `json:"correlation"`
+		} `json:"delay"`
+		Loss struct {
+			Loss        float64 `json:"loss"`
+			Correlation float64 `json:"correlation"`
+		} `json:"loss-random"`
+		Duplicate struct {
+			Duplicate float64 `json:"duplicate"`
+		} `json:"duplicate"`
+		Reorder struct {
+			Reorder float64 `json:"reorder"`
+		} `json:"reorder"`
+		Corrupt struct {
+			Corrupt float64 `json:"corrupt"`
+		} `json:"corrupt"`
+		Rate  int64 `json:"rate"`
+		Burst int   `json:"burst"`
+	} `json:"options"`
 }
 
-func (ne *NetworkEmulation) SetLoss(loss int) error {
-	ne.Loss = loss
-	return ne.Apply()
+// queryImpairment runs `tc -j qdisc show dev dev` and translates the netem
+// (and, if present, tbf) qdiscs it finds back into an Impairment, using
+// fallback for any field the parse can't recover.
+func queryImpairment(dev string, fallback Impairment) (Impairment, error) {
+	output, err := exec.Command("tc", "-j", "qdisc", "show", "dev", dev).CombinedOutput()
+	if err != nil {
+		return Impairment{}, fmt.Errorf("tc qdisc show: %v, output: %s", err, string(output))
+	}
+
+	var qdiscs []tcQdisc
+	if err := json.Unmarshal(output, &qdiscs); err != nil {
+		return Impairment{}, fmt.Errorf("failed to parse tc qdisc show output: %v", err)
+	}
+
+	imp := Impairment{JitterDist: fallback.JitterDist, QueueLimit: fallback.QueueLimit, LatencyMs: fallback.LatencyMs}
+	for _, q := range qdiscs {
+		switch q.Kind {
+		case "netem":
+			imp.DelayMs = int(q.Options.Delay.Delay / 1000)
+			imp.JitterMs = int(q.Options.Delay.Jitter / 1000)
+			imp.LossPct = q.Options.Loss.Loss
+			imp.LossCorrelation = q.Options.Loss.Correlation
+			imp.DuplicatePct = q.Options.Duplicate.Duplicate
+			imp.ReorderPct = q.Options.Reorder.Reorder
+			imp.CorruptPct = q.Options.Corrupt.Corrupt
+			if q.Options.Limit > 0 {
+				imp.QueueLimit = q.Options.Limit
+			}
+		case "tbf":
+			imp.BandwidthKbps = int(q.Options.Rate * 8 / 1000)
+			imp.BurstBytes = q.Options.Burst
+		}
+	}
+	return imp, nil
 }
 
-func (ne *NetworkEmulation) SetBandwidth(bandwidth int) error {
-	ne.Bandwidth = bandwidth
-	return ne.Apply()
+// NetworkProfiles maps a named real-world scenario to the Impairment that
+// approximates it, so the benchmark harness can sweep realistic conditions
+// without hand-tuning netem parameters per run. Figures are rough,
+// commonly-cited approximations, not measurements of any specific carrier.
+var NetworkProfiles = map[string]Impairment{
+	"3g": {
+		DelayMs: 100, JitterMs: 40, JitterDist: DistributionNormal,
+		LossPct: 1, BandwidthKbps: 1600,
+	},
+	"satellite": {
+		DelayMs: 600, JitterMs: 20, JitterDist: DistributionNormal,
+		LossPct: 0.5, BandwidthKbps: 10000, BurstBytes: 65536, LatencyMs: 100,
+	},
+	"lossy-wifi": {
+		DelayMs: 20, JitterMs: 10, JitterDist: DistributionNormal,
+		LossPct: 3, LossCorrelation: 25, ReorderPct: 5, CorruptPct: 1,
+	},
 }
 
-func (ne *NetworkEmulation) SetConditions(delay, loss int) error {
-	ne.Delay = delay
-	ne.Loss = loss
-	return ne.Apply()
+// LookupProfile returns the named profile's Impairment, or false if name
+// isn't one of NetworkProfiles' keys.
+func LookupProfile(name string) (Impairment, bool) {
+	imp, ok := NetworkProfiles[name]
+	return imp, ok
 }
 
-func (ne *NetworkEmulation) SetAllConditions(delay, loss, bandwidth int) error {
-	ne.Delay = delay
-	ne.Loss = loss
-	ne.Bandwidth = bandwidth
+// SetImpairment applies a new up/down impairment pair.
+func (ne *NetworkEmulation) SetImpairment(up, down Impairment, asymmetric bool) error {
+	ne.Up = up
+	ne.Down = down
+	ne.Asymmetric = asymmetric
 	return ne.Apply()
 }
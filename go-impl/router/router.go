@@ -1,32 +1,65 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
+	"encoding/binary"
 	"encoding/json"
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
+	"github.com/quic-go/quic-go/http3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/protobuf/proto"
+
 	"grpc-over-http3/common"
 )
 
+// echoServiceHealthName must match the name server/server.go registers its
+// grpc/health service status under.
+const echoServiceHealthName = "echo.EchoService"
+
+// grpcHealthProbeTimeout bounds how long handleHealthCheck waits for each
+// upstream health probe, so a dead backend fails the aggregate check
+// quickly instead of hanging the router's own /health response.
+const grpcHealthProbeTimeout = 2 * time.Second
+
 type RouterServer struct {
 	emulation *NetworkEmulation
 	logger    *common.Logger
+
+	// upstreamAddr, upstreamHTTP2Port and upstreamHTTP3Port locate the app
+	// server handleHealthCheck's grpc probe targets.
+	upstreamAddr      string
+	upstreamHTTP2Port int
+	upstreamHTTP3Port int
 }
 
+// NetworkConfigRequest is the full netem-style impairment matrix the sweep
+// engine in latency_benchmark.go drives the router with. Down/Asymmetric are
+// only meaningful when Asymmetric is true; otherwise Up is applied to both
+// directions.
 type NetworkConfigRequest struct {
-	Delay int `json:"delay"`
-	Loss  int `json:"loss"`
+	Up         Impairment `json:"up"`
+	Down       Impairment `json:"down"`
+	Asymmetric bool       `json:"asymmetric"`
 }
 
 type NetworkStatusResponse struct {
-	Delay int `json:"delay"`
-	Loss  int `json:"loss"`
+	Up         Impairment `json:"up"`
+	Down       Impairment `json:"down"`
+	Asymmetric bool       `json:"asymmetric"`
 }
 
 func (rs *RouterServer) handleSetNetworkConfig(w http.ResponseWriter, r *http.Request) {
@@ -41,9 +74,20 @@ func (rs *RouterServer) handleSetNetworkConfig(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	rs.logger.Info("Setting network configuration", "delay", config.Delay, "loss", config.Loss)
+	if err := config.Up.Validate(); err != nil {
+		http.Error(w, fmt.Sprintf("invalid up impairment: %v", err), http.StatusBadRequest)
+		return
+	}
+	if config.Asymmetric {
+		if err := config.Down.Validate(); err != nil {
+			http.Error(w, fmt.Sprintf("invalid down impairment: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	rs.logger.Info("Setting network configuration", "up", config.Up, "down", config.Down, "asymmetric", config.Asymmetric)
 
-	if err := rs.emulation.SetConditions(config.Delay, config.Loss); err != nil {
+	if err := rs.emulation.SetImpairment(config.Up, config.Down, config.Asymmetric); err != nil {
 		rs.logger.Error("Failed to set network conditions", "error", err)
 		http.Error(w, fmt.Sprintf("Failed to set network conditions: %v", err), http.StatusInternalServerError)
 		return
@@ -59,7 +103,7 @@ func (rs *RouterServer) handleGetNetworkStatus(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	delay, loss, err := rs.emulation.GetStatus()
+	up, down, asymmetric, err := rs.emulation.GetStatus()
 	if err != nil {
 		rs.logger.Error("Failed to get network status", "error", err)
 		http.Error(w, fmt.Sprintf("Failed to get network status: %v", err), http.StatusInternalServerError)
@@ -67,16 +111,165 @@ func (rs *RouterServer) handleGetNetworkStatus(w http.ResponseWriter, r *http.Re
 	}
 
 	status := NetworkStatusResponse{
-		Delay: delay,
-		Loss:  loss,
+		Up:         up,
+		Down:       down,
+		Asymmetric: asymmetric,
 	}
 
 	json.NewEncoder(w).Encode(status)
 }
 
-func (rs *RouterServer) handleHealthCheck(w http.ResponseWriter, r *http.Request) {
+// NetworkProfileRequest names a preset scenario from NetworkProfiles;
+// Asymmetric applies it to both directions independently when true,
+// otherwise the same Impairment is used for up and down.
+type NetworkProfileRequest struct {
+	Profile    string `json:"profile"`
+	Asymmetric bool   `json:"asymmetric"`
+}
+
+func (rs *RouterServer) handleSetNetworkProfile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req NetworkProfileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	imp, ok := LookupProfile(req.Profile)
+	if !ok {
+		http.Error(w, fmt.Sprintf("unknown profile %q", req.Profile), http.StatusBadRequest)
+		return
+	}
+
+	rs.logger.Info("Applying network profile", "profile", req.Profile, "impairment", imp, "asymmetric", req.Asymmetric)
+
+	if err := rs.emulation.SetImpairment(imp, imp, req.Asymmetric); err != nil {
+		rs.logger.Error("Failed to apply network profile", "error", err)
+		http.Error(w, fmt.Sprintf("Failed to apply network profile: %v", err), http.StatusInternalServerError)
+		return
+	}
+
 	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("OK"))
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "profile": req.Profile})
+}
+
+// HealthStatusResponse is handleHealthCheck's JSON body when a gRPC probe is
+// requested: per-protocol serving status plus the impairment currently
+// applied, so a dashboard or benchmark can tell "server down" apart from
+// "server up but network degraded" at a glance.
+type HealthStatusResponse struct {
+	HTTP2        string                `json:"http2"`
+	HTTP3        string                `json:"http3"`
+	NetemApplied NetworkStatusResponse `json:"netem_applied"`
+}
+
+// handleHealthCheck answers a plain liveness check by default. With
+// ?probe=grpc it additionally dials the upstream app server's HTTP/2 and
+// HTTP/3 ports and checks EchoService's status via the standard gRPC health
+// protocol, aggregating both alongside the currently applied impairment.
+func (rs *RouterServer) handleHealthCheck(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("probe") != "grpc" {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("OK"))
+		return
+	}
+
+	up, down, asymmetric, err := rs.emulation.GetStatus()
+	if err != nil {
+		rs.logger.Error("Failed to get network status for health probe", "error", err)
+		http.Error(w, fmt.Sprintf("Failed to get network status: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	status := HealthStatusResponse{
+		HTTP2:        probeGRPCHealth(fmt.Sprintf("%s:%d", rs.upstreamAddr, rs.upstreamHTTP2Port)),
+		HTTP3:        probeGRPCHealthOverHTTP3(fmt.Sprintf("%s:%d", rs.upstreamAddr, rs.upstreamHTTP3Port)),
+		NetemApplied: NetworkStatusResponse{Up: up, Down: down, Asymmetric: asymmetric},
+	}
+
+	json.NewEncoder(w).Encode(status)
+}
+
+// probeGRPCHealth dials addr and checks EchoService's status via the
+// standard gRPC health protocol, returning the ServingStatus name or
+// "UNKNOWN" if the dial or the check itself fails - a dead backend should
+// degrade the aggregate response, not hang or 500 it.
+func probeGRPCHealth(addr string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), grpcHealthProbeTimeout)
+	defer cancel()
+
+	conn, err := grpc.DialContext(ctx, addr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithBlock())
+	if err != nil {
+		return healthpb.HealthCheckResponse_UNKNOWN.String()
+	}
+	defer conn.Close()
+
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: echoServiceHealthName})
+	if err != nil {
+		return healthpb.HealthCheckResponse_UNKNOWN.String()
+	}
+	return resp.Status.String()
+}
+
+// probeGRPCHealthOverHTTP3 checks EchoService's status the same way
+// probeGRPCHealth does, but over the app server's actual HTTP/3 (QUIC)
+// listener: grpc.DialContext has no QUIC transport, so plain grpc.Dial
+// against the HTTP/3 port always fails and never reflects real health. This
+// hand-frames the gRPC wire format onto an http3.RoundTripper-backed
+// *http.Client instead, the same trick workload.GRPCOverHTTP3UnaryWorkload
+// uses against EchoService.
+func probeGRPCHealthOverHTTP3(addr string) string {
+	reqPayload, err := proto.Marshal(&healthpb.HealthCheckRequest{Service: echoServiceHealthName})
+	if err != nil {
+		return healthpb.HealthCheckResponse_UNKNOWN.String()
+	}
+
+	var body bytes.Buffer
+	frameHeader := make([]byte, 5)
+	binary.BigEndian.PutUint32(frameHeader[1:], uint32(len(reqPayload)))
+	body.Write(frameHeader)
+	body.Write(reqPayload)
+
+	ctx, cancel := context.WithTimeout(context.Background(), grpcHealthProbeTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("https://%s/grpc.health.v1.Health/Check", addr), &body)
+	if err != nil {
+		return healthpb.HealthCheckResponse_UNKNOWN.String()
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("TE", "trailers")
+
+	client := &http.Client{
+		Transport: &http3.RoundTripper{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+		Timeout:   grpcHealthProbeTimeout,
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return healthpb.HealthCheckResponse_UNKNOWN.String()
+	}
+	defer resp.Body.Close()
+
+	respHeader := make([]byte, 5)
+	if _, err := io.ReadFull(resp.Body, respHeader); err != nil {
+		return healthpb.HealthCheckResponse_UNKNOWN.String()
+	}
+	respPayload := make([]byte, binary.BigEndian.Uint32(respHeader[1:]))
+	if _, err := io.ReadFull(resp.Body, respPayload); err != nil {
+		return healthpb.HealthCheckResponse_UNKNOWN.String()
+	}
+
+	var respMsg healthpb.HealthCheckResponse
+	if err := proto.Unmarshal(respPayload, &respMsg); err != nil {
+		return healthpb.HealthCheckResponse_UNKNOWN.String()
+	}
+	return respMsg.Status.String()
 }
 
 func (rs *RouterServer) handleClearNetworkConfig(w http.ResponseWriter, r *http.Request) {
@@ -98,36 +291,61 @@ func (rs *RouterServer) handleClearNetworkConfig(w http.ResponseWriter, r *http.
 }
 
 func main() {
+	configPath := flag.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML config file (see common.Config) whose network_delay/network_loss are hot-reloaded into the emulation's baseline impairment")
+	flag.Parse()
+
 	logger := common.NewLogger("INFO")
 	logger.Info("Starting gRPC network emulation router")
 
 	// ネットワークエミュレーション初期化
-	emulation := &NetworkEmulation{
-		Delay: 0,
-		Loss:  0,
-	}
+	emulation := &NetworkEmulation{Logger: logger}
 
 	// 初期状態をクリア
 	if err := emulation.Clear(); err != nil {
 		logger.Error("Failed to clear initial network state", "error", err)
 	}
 
+	// 設定ファイルが指定されていれば、network_delay/network_lossの変更を
+	// 監視し、変更のたびに上り方向のimpairmentをその値だけのものに置き換える
+	// （/network/configで設定した上り側の値も上書きされ、下り側とAsymmetricは
+	// そのまま残る）。コンテナ再起動なしでスイープできる。
+	if *configPath != "" {
+		configWatcher, err := common.NewConfigWatcher(*configPath)
+		if err != nil {
+			logger.Warn("Failed to start config hot-reload watcher", "error", err)
+		} else {
+			defer configWatcher.Close()
+			configWatcher.Subscribe(func(reloaded *common.Config) {
+				baseline := Impairment{DelayMs: reloaded.NetworkDelay, LossPct: float64(reloaded.NetworkLoss)}
+				if err := emulation.SetImpairment(baseline, emulation.Down, emulation.Asymmetric); err != nil {
+					logger.Error("Failed to apply reloaded network config", "error", err)
+				} else {
+					logger.Info("Applied reloaded network config", "delay_ms", baseline.DelayMs, "loss_pct", baseline.LossPct)
+				}
+			})
+		}
+	}
+
 	// ルーターサーバー初期化
 	router := &RouterServer{
-		emulation: emulation,
-		logger:    logger,
+		emulation:         emulation,
+		logger:            logger,
+		upstreamAddr:      envOrDefault("UPSTREAM_ADDR", "server"),
+		upstreamHTTP2Port: envIntOrDefault("UPSTREAM_HTTP2_PORT", 443),
+		upstreamHTTP3Port: envIntOrDefault("UPSTREAM_HTTP3_PORT", 4433),
 	}
 
 	// HTTP サーバー設定
 	mux := http.NewServeMux()
 	mux.HandleFunc("/network/config", router.handleSetNetworkConfig)
 	mux.HandleFunc("/network/status", router.handleGetNetworkStatus)
+	mux.HandleFunc("/network/profile", router.handleSetNetworkProfile)
 	mux.HandleFunc("/network/clear", router.handleClearNetworkConfig)
 	mux.HandleFunc("/health", router.handleHealthCheck)
 
 	server := &http.Server{
 		Addr:    ":8080",
-		Handler: mux,
+		Handler: common.RequestIDMiddleware(logger, mux),
 	}
 
 	// サーバー起動
@@ -160,3 +378,19 @@ func main() {
 
 	logger.Info("Router server stopped")
 }
+
+func envOrDefault(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func envIntOrDefault(key string, fallback int) int {
+	if v := os.Getenv(key); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			return n
+		}
+	}
+	return fallback
+}
@@ -7,19 +7,23 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
+
+	"grpc-over-http3/stats"
 )
 
 type LatencyResult struct {
-	Protocol   string  `json:"protocol"`
-	Delay      int     `json:"delay_ms"`
-	Requests   int     `json:"requests"`
-	Successes  int     `json:"successes"`
-	MinLatency float64 `json:"min_latency_ns"` // ナノ秒単位
-	MaxLatency float64 `json:"max_latency_ns"` // ナノ秒単位
-	AvgLatency float64 `json:"avg_latency_ns"` // ナノ秒単位
-	P95Latency float64 `json:"p95_latency_ns"` // ナノ秒単位
-	P99Latency float64 `json:"p99_latency_ns"` // ナノ秒単位
+	Protocol   string    `json:"protocol"`
+	Delay      int       `json:"delay_ms"`
+	Requests   int       `json:"requests"`
+	Successes  int       `json:"successes"`
+	MinLatency float64   `json:"min_latency_ns"`      // ナノ秒単位
+	MaxLatency float64   `json:"max_latency_ns"`      // ナノ秒単位
+	AvgLatency float64   `json:"avg_latency_ns"`      // ナノ秒単位
+	P95Latency float64   `json:"p95_latency_ns"`      // ナノ秒単位
+	P99Latency float64   `json:"p99_latency_ns"`      // ナノ秒単位
+	Latencies  []float64 `json:"latencies,omitempty"` // 生のリクエスト毎レイテンシ（ナノ秒）。latency_benchmark.goのLatencyResult.Latenciesと同じJSONタグ（`latencies`）に合わせる
 }
 
 type RunAnalysis struct {
@@ -37,8 +41,26 @@ type ComparisonSummary struct {
 	HTTP2Max float64
 	HTTP3Min float64
 	HTTP3Max float64
+
+	// MeanDiffCILow/High is the bootstrap 95% CI on (HTTP/3 mean - HTTP/2
+	// mean), in the same unit as HTTP2Avg/HTTP3Avg.
+	MeanDiffCILow  float64
+	MeanDiffCIHigh float64
+
+	// PValue is the one-sided Mann-Whitney U p-value for "HTTP/3 is faster
+	// than HTTP/2" at this delay.
+	PValue float64
+
+	// PooledP95/PooledP99 are recomputed across every raw per-request sample
+	// from every run, rather than averaging pre-summarized percentiles.
+	HTTP2PooledP95 float64
+	HTTP2PooledP99 float64
+	HTTP3PooledP95 float64
+	HTTP3PooledP99 float64
 }
 
+const bootstrapIterations = 10000
+
 func main() {
 	if len(os.Args) < 2 {
 		log.Fatal("使用方法: go run analyze_multiple_results.go <summary_directory>")
@@ -92,6 +114,7 @@ func main() {
 
 	for _, delay := range delays {
 		var http2Avgs, http3Avgs []float64
+		var http2Pooled, http3Pooled []float64
 
 		// 各実行から該当遅延の結果を抽出
 		for _, run := range runAnalyses {
@@ -99,24 +122,36 @@ func main() {
 				if result.Delay == delay {
 					if result.Protocol == "HTTP/2" {
 						http2Avgs = append(http2Avgs, result.AvgLatency)
+						http2Pooled = append(http2Pooled, result.Latencies...)
 					} else if result.Protocol == "HTTP/3" {
 						http3Avgs = append(http3Avgs, result.AvgLatency)
+						http3Pooled = append(http3Pooled, result.Latencies...)
 					}
 				}
 			}
 		}
 
 		if len(http2Avgs) > 0 && len(http3Avgs) > 0 {
+			ciLow, ciHigh := stats.BootstrapMeanDiffCI(http2Avgs, http3Avgs, bootstrapIterations)
+			_, pValue := stats.MannWhitneyU(http3Avgs, http2Avgs)
+
 			comparison := ComparisonSummary{
-				Delay:    delay,
-				HTTP2Avg: http2Avgs,
-				HTTP3Avg: http3Avgs,
-				HTTP2Std: calculateStdDev(http2Avgs),
-				HTTP3Std: calculateStdDev(http3Avgs),
-				HTTP2Min: min(http2Avgs),
-				HTTP2Max: max(http2Avgs),
-				HTTP3Min: min(http3Avgs),
-				HTTP3Max: max(http3Avgs),
+				Delay:          delay,
+				HTTP2Avg:       http2Avgs,
+				HTTP3Avg:       http3Avgs,
+				HTTP2Std:       stats.StdDev(http2Avgs),
+				HTTP3Std:       stats.StdDev(http3Avgs),
+				HTTP2Min:       stats.Min(http2Avgs),
+				HTTP2Max:       stats.Max(http2Avgs),
+				HTTP3Min:       stats.Min(http3Avgs),
+				HTTP3Max:       stats.Max(http3Avgs),
+				MeanDiffCILow:  ciLow,
+				MeanDiffCIHigh: ciHigh,
+				PValue:         pValue,
+				HTTP2PooledP95: percentile(http2Pooled, 95),
+				HTTP2PooledP99: percentile(http2Pooled, 99),
+				HTTP3PooledP95: percentile(http3Pooled, 95),
+				HTTP3PooledP99: percentile(http3Pooled, 99),
 			}
 			comparisons = append(comparisons, comparison)
 		}
@@ -130,16 +165,24 @@ func main() {
 	for _, comp := range comparisons {
 		fmt.Printf("\n遅延 %dms:\n", comp.Delay)
 		fmt.Printf("  HTTP/2: 平均=%.3fms, 標準偏差=%.3fms, 範囲=[%.3f-%.3f]ms\n",
-			average(comp.HTTP2Avg), comp.HTTP2Std, comp.HTTP2Min, comp.HTTP2Max)
+			stats.Mean(comp.HTTP2Avg), comp.HTTP2Std, comp.HTTP2Min, comp.HTTP2Max)
 		fmt.Printf("  HTTP/3: 平均=%.3fms, 標準偏差=%.3fms, 範囲=[%.3f-%.3f]ms\n",
-			average(comp.HTTP3Avg), comp.HTTP3Std, comp.HTTP3Min, comp.HTTP3Max)
+			stats.Mean(comp.HTTP3Avg), comp.HTTP3Std, comp.HTTP3Min, comp.HTTP3Max)
 
 		// 安定性評価
-		http2Stability := evaluateStability(comp.HTTP2Std, average(comp.HTTP2Avg))
-		http3Stability := evaluateStability(comp.HTTP3Std, average(comp.HTTP3Avg))
+		http2Stability := evaluateStability(comp.HTTP2Std, stats.Mean(comp.HTTP2Avg))
+		http3Stability := evaluateStability(comp.HTTP3Std, stats.Mean(comp.HTTP3Avg))
+
+		fmt.Printf("  HTTP/2 安定性: %s (CV=%.1f%%)\n", http2Stability, (comp.HTTP2Std/stats.Mean(comp.HTTP2Avg))*100)
+		fmt.Printf("  HTTP/3 安定性: %s (CV=%.1f%%)\n", http3Stability, (comp.HTTP3Std/stats.Mean(comp.HTTP3Avg))*100)
 
-		fmt.Printf("  HTTP/2 安定性: %s (CV=%.1f%%)\n", http2Stability, (comp.HTTP2Std/average(comp.HTTP2Avg))*100)
-		fmt.Printf("  HTTP/3 安定性: %s (CV=%.1f%%)\n", http3Stability, (comp.HTTP3Std/average(comp.HTTP3Avg))*100)
+		fmt.Printf("  HTTP/3 - HTTP/2 平均差の95%%信頼区間: [%.3f, %.3f]ms\n", comp.MeanDiffCILow, comp.MeanDiffCIHigh)
+		fmt.Printf("  HTTP/3がHTTP/2より速いというMann-Whitney U検定のp値: %.4f\n", comp.PValue)
+
+		if comp.HTTP2PooledP95 > 0 || comp.HTTP3PooledP95 > 0 {
+			fmt.Printf("  全リクエストを統合したP95: HTTP/2=%.3fms, HTTP/3=%.3fms\n", comp.HTTP2PooledP95/1e6, comp.HTTP3PooledP95/1e6)
+			fmt.Printf("  全リクエストを統合したP99: HTTP/2=%.3fms, HTTP/3=%.3fms\n", comp.HTTP2PooledP99/1e6, comp.HTTP3PooledP99/1e6)
+		}
 	}
 
 	// 詳細結果をCSVに出力
@@ -151,73 +194,19 @@ func main() {
 	fmt.Println("================================================")
 }
 
-func calculateStdDev(values []float64) float64 {
-	if len(values) == 0 {
-		return 0
-	}
-
-	avg := average(values)
-	sum := 0.0
-	for _, v := range values {
-		sum += (v - avg) * (v - avg)
-	}
-	return sqrt(sum / float64(len(values)))
-}
-
-func average(values []float64) float64 {
-	if len(values) == 0 {
-		return 0
-	}
-
-	sum := 0.0
-	for _, v := range values {
-		sum += v
-	}
-	return sum / float64(len(values))
-}
-
-func min(values []float64) float64 {
-	if len(values) == 0 {
-		return 0
-	}
-
-	min := values[0]
-	for _, v := range values {
-		if v < min {
-			min = v
-		}
-	}
-	return min
-}
-
-func max(values []float64) float64 {
-	if len(values) == 0 {
-		return 0
-	}
-
-	max := values[0]
-	for _, v := range values {
-		if v > max {
-			max = v
-		}
-	}
-	return max
-}
-
-func sqrt(x float64) float64 {
-	// 簡易平方根実装
-	if x < 0 {
-		return 0
-	}
-	if x == 0 {
+// percentile recomputes a percentile directly from pooled raw samples
+// (nanoseconds), rather than averaging each run's pre-summarized percentile.
+func percentile(samples []float64, p float64) float64 {
+	if len(samples) == 0 {
 		return 0
 	}
-
-	guess := x / 2
-	for i := 0; i < 10; i++ {
-		guess = (guess + x/guess) / 2
+	sorted := append([]float64(nil), samples...)
+	sort.Float64s(sorted)
+	idx := int(float64(len(sorted)) * p / 100)
+	if idx >= len(sorted) {
+		idx = len(sorted) - 1
 	}
-	return guess
+	return sorted[idx]
 }
 
 func evaluateStability(stdDev, avg float64) string {
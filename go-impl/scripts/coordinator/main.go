@@ -0,0 +1,50 @@
+// Command coordinator is the CLI entrypoint for orchestrator.Coordinator: it
+// dispatches one CoordinatorConfig to a fleet of orchestrator.Worker
+// processes (see scripts/worker) and prints the AggregatedResult as JSON.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"grpc-over-http3/orchestrator"
+)
+
+func main() {
+	workersFlag := flag.String("workers", "", "comma-separated worker addresses (host:port) to dispatch the run to")
+	serverAddr := flag.String("server-addr", "172.31.0.2", "server address each worker probes")
+	http2Port := flag.Int("http2-port", 443, "server HTTP/2 port")
+	http3Port := flag.Int("http3-port", 4433, "server HTTP/3 port")
+	requests := flag.Int("requests", 1000, "requests per worker per protocol")
+	timeout := flag.Duration("timeout", 30*time.Second, "per-request timeout")
+	leadTime := flag.Duration("lead-time", 2*time.Second, "how far in the future to schedule StartAt so every worker fires in lockstep")
+	flag.Parse()
+
+	if *workersFlag == "" {
+		log.Fatalf("--workers is required (comma-separated host:port list)")
+	}
+	workers := strings.Split(*workersFlag, ",")
+
+	coordinator := orchestrator.NewCoordinator(workers)
+	result, err := coordinator.Run(orchestrator.CoordinatorConfig{
+		Requests:   *requests,
+		Timeout:    *timeout,
+		ServerAddr: *serverAddr,
+		HTTP2Port:  *http2Port,
+		HTTP3Port:  *http3Port,
+		Workers:    workers,
+	}, *leadTime)
+	if err != nil {
+		log.Fatalf("Coordinator run failed: %v", err)
+	}
+
+	out, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		log.Fatalf("Failed to marshal result: %v", err)
+	}
+	fmt.Println(string(out))
+}
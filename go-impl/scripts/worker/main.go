@@ -0,0 +1,26 @@
+// Command worker is the CLI entrypoint for orchestrator.Worker: it listens
+// for RunRequests from scripts/coordinator and probes the configured server
+// independently, reporting samples back over the same HTTP exchange.
+package main
+
+import (
+	"flag"
+	"log"
+
+	"grpc-over-http3/orchestrator"
+)
+
+func main() {
+	id := flag.String("id", "", "worker ID reported back to the coordinator")
+	addr := flag.String("addr", ":8090", "address to listen for coordinator /run requests on")
+	flag.Parse()
+
+	if *id == "" {
+		log.Fatalf("--id is required")
+	}
+
+	worker := orchestrator.NewWorker(*id)
+	if err := worker.ListenAndServe(*addr); err != nil {
+		log.Fatalf("Worker failed: %v", err)
+	}
+}
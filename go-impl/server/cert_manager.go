@@ -17,6 +17,13 @@ import (
 type CertManager struct {
 	CertPath string
 	KeyPath  string
+
+	// SessionTicketKeyPath, if set, makes the session ticket encryption key
+	// persist across server restarts: crypto/tls otherwise generates a
+	// fresh random key per process, which silently invalidates every ticket
+	// a client is holding the moment the server restarts, making 0-RTT
+	// benchmarks look like they never resume.
+	SessionTicketKeyPath string
 }
 
 func (cm *CertManager) GenerateSelfSignedCert() error {
@@ -84,7 +91,7 @@ func (cm *CertManager) LoadTLSConfig() (*tls.Config, error) {
 		return nil, fmt.Errorf("failed to load key pair: %v", err)
 	}
 
-	return &tls.Config{
+	config := &tls.Config{
 		Certificates: []tls.Certificate{cert},
 		NextProtos:   []string{"h2", "h3", "h3-29", "h3-28", "h3-27"},
 		MinVersion:   tls.VersionTLS12,
@@ -93,5 +100,39 @@ func (cm *CertManager) LoadTLSConfig() (*tls.Config, error) {
 			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
 			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
 		},
-	}, nil
+	}
+
+	if cm.SessionTicketKeyPath != "" {
+		key, err := cm.loadOrCreateSessionTicketKey()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load session ticket key: %v", err)
+		}
+		config.SessionTicketKey = key
+	}
+
+	return config, nil
+}
+
+// loadOrCreateSessionTicketKey reads a 32-byte ticket encryption key from
+// SessionTicketKeyPath, generating and persisting a new random one on first
+// run. Reusing the same key across restarts is what lets a client's 0-RTT
+// ticket from a previous server process still decrypt successfully.
+func (cm *CertManager) loadOrCreateSessionTicketKey() ([32]byte, error) {
+	var key [32]byte
+
+	if data, err := os.ReadFile(cm.SessionTicketKeyPath); err == nil {
+		if len(data) != len(key) {
+			return key, fmt.Errorf("session ticket key file %q has wrong length %d, expected %d", cm.SessionTicketKeyPath, len(data), len(key))
+		}
+		copy(key[:], data)
+		return key, nil
+	}
+
+	if _, err := rand.Read(key[:]); err != nil {
+		return key, fmt.Errorf("failed to generate session ticket key: %v", err)
+	}
+	if err := os.WriteFile(cm.SessionTicketKeyPath, key[:], 0600); err != nil {
+		return key, fmt.Errorf("failed to persist session ticket key: %v", err)
+	}
+	return key, nil
 }
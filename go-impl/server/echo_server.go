@@ -37,3 +37,47 @@ func (s *EchoServer) StreamEcho(stream pb.EchoService_StreamEchoServer) error {
 		}
 	}
 }
+
+// ServerStream echoes a single request back as a continuous stream of
+// responses until the client cancels, so benchmarks can measure first-byte
+// latency and inter-message gap independently of a one-shot unary call.
+func (s *EchoServer) ServerStream(req *pb.EchoRequest, stream pb.EchoService_ServerStreamServer) error {
+	for {
+		select {
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		default:
+			response := &pb.EchoResponse{
+				Message:   req.Message,
+				Timestamp: time.Now().UnixNano(),
+				Protocol:  "HTTP/2",
+			}
+			if err := stream.Send(response); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// BidiStream echoes every request back as soon as it arrives, letting a
+// benchmark open several of these concurrently on one connection to measure
+// whether a stalled sibling stream blocks the others (head-of-line
+// blocking).
+func (s *EchoServer) BidiStream(stream pb.EchoService_BidiStreamServer) error {
+	for {
+		req, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+
+		response := &pb.EchoResponse{
+			Message:   req.Message,
+			Timestamp: time.Now().UnixNano(),
+			Protocol:  "HTTP/2",
+		}
+
+		if err := stream.Send(response); err != nil {
+			return err
+		}
+	}
+}
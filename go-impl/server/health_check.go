@@ -2,20 +2,55 @@ package main
 
 import (
 	"context"
-	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/quic-go/quic-go/http3"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"grpc-over-http3/common"
 )
 
 type GracefulShutdown struct {
-	Server      *http.Server
-	HTTP3Server *http3.Server
-	Timeout     time.Duration
+	Server        *http.Server
+	HTTP3Server   *http3.Server
+	GRPCHealth    *health.Server // optional; marks NOT_SERVING before the listeners stop
+	HealthChecker *HealthChecker // optional; flips /ready to 503 the moment shutdown starts
+	Logger        *common.Logger // optional; falls back to a plain INFO logger if nil
+	Timeout       time.Duration
+
+	// inFlight tracks requests entered through RegisterHandler, so
+	// WaitForShutdown can wait for them to finish draining before issuing
+	// HTTP3Server.Close's CONNECTION_CLOSE, instead of relying solely on
+	// http.Server.Shutdown's and http3.Server.Shutdown's own (separate,
+	// protocol-specific) notions of "in flight".
+	inFlight sync.WaitGroup
+}
+
+// RegisterHandler wraps h so every request it serves is counted in gs's
+// drain wait group. Both the HTTP/2 and HTTP/3 servers should have their
+// handler wrapped with this (the same wrapped handler can be shared by
+// both, as server.go does) so WaitForShutdown's drain wait covers requests
+// on either transport.
+func (gs *GracefulShutdown) RegisterHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gs.inFlight.Add(1)
+		defer gs.inFlight.Done()
+		h.ServeHTTP(w, r)
+	})
+}
+
+// log returns gs.Logger, or a default one if it wasn't set.
+func (gs *GracefulShutdown) log() *common.Logger {
+	if gs.Logger != nil {
+		return gs.Logger
+	}
+	return common.NewLogger("INFO")
 }
 
 func (gs *GracefulShutdown) WaitForShutdown() {
@@ -23,31 +58,87 @@ func (gs *GracefulShutdown) WaitForShutdown() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 
 	<-quit
-	log.Println("Server is shutting down...")
+	gs.log().Info("Server is shutting down...")
+
+	// /readyを即座に503へ倒し、ロードバランサーがリスナーの停止を待たずに
+	// 新規トラフィックをこのインスタンスへ振るのをやめられるようにする。
+	// /healthはプロセスが終了するまで200を返し続ける（liveness用途のため）。
+	if gs.HealthChecker != nil {
+		gs.HealthChecker.SetReady(false)
+	}
+
+	// gRPCヘルスチェックを先にNOT_SERVINGへ倒し、ロードバランサー/ベンチマーク
+	// 側がリスナーが実際に閉じる前から新規リクエストを止められるようにする
+	if gs.GRPCHealth != nil {
+		gs.GRPCHealth.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+		gs.GRPCHealth.SetServingStatus(echoServiceHealthName, healthpb.HealthCheckResponse_NOT_SERVING)
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), gs.Timeout)
 	defer cancel()
 
-	// HTTP/2 サーバー停止
+	// HTTP/2 サーバー停止（新規接続の受付を止め、既存リクエストの完了を待つ）
 	if gs.Server != nil {
 		if err := gs.Server.Shutdown(ctx); err != nil {
-			log.Printf("HTTP/2 server shutdown error: %v", err)
+			gs.log().Error("HTTP/2 server shutdown error", "error", err)
 		}
 	}
 
-	// HTTP/3 サーバー停止
+	// HTTP/3はShutdownの呼び出し自体が新規QUIC接続/ストリームの受付を止める
+	// 最初の動作なので、inFlight.Waitの前に呼ぶ。Shutdownはctxがcancelされる
+	// かアイドルになるまでブロックし得るため、下のinFlight.Waitと直列にせず
+	// ゴルーチンで並行に走らせる（直列にすると新規ストリームがinFlight.Wait
+	// の間ずっと受け付けられてしまい、待ち時間も二重になる）。
+	if gs.HTTP3Server != nil {
+		go func() {
+			if err := gs.HTTP3Server.Shutdown(ctx); err != nil {
+				gs.log().Error("HTTP/3 server graceful shutdown error", "error", err)
+			}
+		}()
+	}
+
+	// RegisterHandlerでラップされたハンドラが処理中のリクエストを、Timeoutを
+	// 上限に待つ。HTTP/2側はServer.Shutdownが新規接続を止めた後、HTTP/3側は
+	// 上のShutdownが新規受付を止めた後なので、ここで残るのは双方の
+	// ハンドラ実行中リクエストのみ。
+	drained := make(chan struct{})
+	go func() {
+		gs.inFlight.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		gs.log().Info("All in-flight requests drained")
+	case <-ctx.Done():
+		gs.log().Warn("Drain timeout exceeded; proceeding with shutdown while requests may still be in flight")
+	}
+
+	// ドレイン完了(またはタイムアウト)後、CONNECTION_CLOSEが確実に送られる
+	// よう強制クローズする。上のShutdownゴルーチンがすでに完了して
+	// いればこれは実質no-op。
 	if gs.HTTP3Server != nil {
 		if err := gs.HTTP3Server.Close(); err != nil {
-			log.Printf("HTTP/3 server shutdown error: %v", err)
+			gs.log().Error("HTTP/3 server shutdown error", "error", err)
 		}
 	}
 
-	log.Println("Server stopped")
+	gs.log().Info("Server stopped")
 }
 
 type HealthChecker struct {
 	server *http.Server
-	ready  bool
+	Logger *common.Logger // optional; falls back to a plain INFO logger if nil
+
+	mu    sync.Mutex
+	ready bool
+}
+
+// log returns hc.Logger, or a default one if it wasn't set.
+func (hc *HealthChecker) log() *common.Logger {
+	if hc.Logger != nil {
+		return hc.Logger
+	}
+	return common.NewLogger("INFO")
 }
 
 func (hc *HealthChecker) StartHealthCheck() {
@@ -62,7 +153,7 @@ func (hc *HealthChecker) StartHealthCheck() {
 
 	go func() {
 		if err := hc.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Printf("Health check server error: %v", err)
+			hc.log().Error("Health check server error", "error", err)
 		}
 	}()
 }
@@ -73,7 +164,11 @@ func (hc *HealthChecker) healthHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func (hc *HealthChecker) readyHandler(w http.ResponseWriter, r *http.Request) {
-	if hc.ready {
+	hc.mu.Lock()
+	ready := hc.ready
+	hc.mu.Unlock()
+
+	if ready {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("Ready"))
 	} else {
@@ -83,5 +178,7 @@ func (hc *HealthChecker) readyHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func (hc *HealthChecker) SetReady(ready bool) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
 	hc.ready = ready
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
@@ -8,21 +9,77 @@ import (
 	"path/filepath"
 	"time"
 
+	"github.com/quic-go/quic-go/http3"
 	"golang.org/x/net/http2"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/reflection"
 
+	"grpc-over-http3/benchmark"
+	"grpc-over-http3/cert"
 	"grpc-over-http3/common"
 	pb "grpc-over-http3/proto"
+	bpb "grpc-over-http3/proto/benchmark"
 )
 
+// certRotationCheckInterval is how often the server checks whether its
+// self-signed certificate is close enough to expiry to auto-rotate.
+const certRotationCheckInterval = 1 * time.Hour
+
+// certExpiryThreshold is how far ahead of NotAfter the server rotates the
+// certificate, giving the cert.Watcher time to pick up the replacement
+// before the old one actually expires.
+const certExpiryThreshold = 24 * time.Hour
+
+// echoServiceHealthName is the fully-qualified service name EchoService is
+// registered under for per-service health checks, matching the package/
+// service names in proto/echo.proto.
+const echoServiceHealthName = "echo.EchoService"
+
+// altSvcMiddleware advertises the HTTP/3 endpoint to HTTP/2 clients so they
+// can upgrade to QUIC for subsequent requests.
+func altSvcMiddleware(next http.Handler, http3Port int) http.Handler {
+	altSvc := fmt.Sprintf(`h3=":%d"; ma=86400`, http3Port)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Alt-Svc", altSvc)
+		next.ServeHTTP(w, r)
+	})
+}
+
 func main() {
-	// 設定読み込み
-	config := common.LoadConfig()
+	configPath := flag.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML config file overlaying the env-var defaults (see common.Config)")
+	flag.Parse()
+
+	// 設定読み込み（YAMLファイル → 環境変数の順で重ね、範囲検証まで行う）
+	config, err := common.LoadConfig(*configPath)
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
 	logger := common.NewLogger(config.LogLevel)
 
 	logger.Info("Starting gRPC over HTTP/2 and HTTP/3 server")
 
+	// 設定ファイルが指定されていれば、LogLevel/NetworkDelay/NetworkLoss/
+	// MaxConnectionsの変更を監視してログに記録する。稼働中のサーバーには
+	// これらの値は適用されない（ロガーのレベルも変わらない）。
+	// CertPath等リスナーに関わる項目は次回起動まで反映されない。
+	if *configPath != "" {
+		configWatcher, err := common.NewConfigWatcher(*configPath)
+		if err != nil {
+			logger.Warn("Failed to start config hot-reload watcher", "error", err)
+		} else {
+			defer configWatcher.Close()
+			configWatcher.Subscribe(func(reloaded *common.Config) {
+				logger.Info("Config reloaded",
+					"log_level", reloaded.LogLevel,
+					"network_delay", reloaded.NetworkDelay,
+					"network_loss", reloaded.NetworkLoss,
+					"max_connections", reloaded.MaxConnections)
+			})
+		}
+	}
+
 	// 証明書ディレクトリ作成
 	certDir := filepath.Dir(config.CertPath)
 	if err := os.MkdirAll(certDir, 0755); err != nil {
@@ -31,8 +88,9 @@ func main() {
 
 	// 証明書管理
 	certManager := &CertManager{
-		CertPath: config.CertPath,
-		KeyPath:  config.KeyPath,
+		CertPath:             config.CertPath,
+		KeyPath:              config.KeyPath,
+		SessionTicketKeyPath: filepath.Join(certDir, "session_ticket.key"),
 	}
 
 	// 証明書生成（存在しない場合）
@@ -43,25 +101,85 @@ func main() {
 		}
 	}
 
-	// TLS設定読み込み（現在は使用しない）
-	// tlsConfig, err := certManager.LoadTLSConfig()
-	// if err != nil {
-	// 	log.Printf("Warning: Failed to load TLS config: %v", err)
-	// }
+	// TLS設定読み込み（HTTP/2とHTTP/3で共有）
+	tlsConfig, err := certManager.LoadTLSConfig()
+	if err != nil {
+		log.Fatalf("Failed to load TLS config: %v", err)
+	}
+	tlsConfig.NextProtos = []string{"h3", "h2"}
+
+	// cert.Watcherにcertをfsnotifyで監視させ、GetCertificate経由で差し替える。
+	// tlsConfig.Certificatesの静的スライスは使わないので外す。
+	certWatcher, err := cert.NewWatcher(config.CertPath, config.KeyPath)
+	if err != nil {
+		log.Fatalf("Failed to start certificate watcher: %v", err)
+	}
+	defer certWatcher.Close()
+	tlsConfig.Certificates = nil
+	tlsConfig.GetCertificate = certWatcher.GetCertificate
+
+	// AutoRotatorが期限切れ間近の自己署名証明書を書き換え、certWatcherがその
+	// 変更をfsnotify経由で拾ってリスナー無停止で差し替える。
+	autoRotator := &cert.AutoRotator{
+		CertPath:        config.CertPath,
+		KeyPath:         config.KeyPath,
+		SANs:            []string{"localhost", "grpc-server.local", "172.30.0.2", "127.0.0.1"},
+		CommonName:      "grpc-server.local",
+		ValidFor:        365 * 24 * time.Hour,
+		ExpiryThreshold: certExpiryThreshold,
+	}
+	go func() {
+		ticker := time.NewTicker(certRotationCheckInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			rotated, err := autoRotator.CheckAndRotate()
+			if err != nil {
+				logger.Warn("Certificate rotation check failed", "error", err)
+				continue
+			}
+			if rotated {
+				logger.Info("Certificate auto-rotated ahead of expiry", "cert_path", config.CertPath)
+			}
+		}
+	}()
 
 	// ヘルスチェック起動
-	healthChecker := &HealthChecker{}
+	healthChecker := &HealthChecker{Logger: logger}
 	healthChecker.StartHealthCheck()
 
 	// gRPCサーバー作成（プレーンテキスト）
 	grpcServer := grpc.NewServer()
 	pb.RegisterEchoServiceServer(grpcServer, &EchoServer{})
+	bpb.RegisterBenchmarkServiceServer(grpcServer, &benchmark.BenchmarkServer{Protocol: "HTTP/2"})
+	bpb.RegisterWorkerServiceServer(grpcServer, &benchmark.WorkerServer{})
 	reflection.Register(grpcServer)
 
-	// HTTP/2 サーバー（TLS無効）
+	// 標準のgRPCヘルスチェックプロトコルを登録し、overallとEchoServiceの両方を
+	// SERVINGにする。HTTP/2・HTTP/3は同じgrpcServerを共有しているので、この
+	// 1回の登録でどちらのポート経由でも健全性が問える。
+	grpcHealth := health.NewServer()
+	grpcHealth.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	grpcHealth.SetServingStatus(echoServiceHealthName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(grpcServer, grpcHealth)
+
+	// グレースフルシャットダウン（ハンドラより先に構築し、RegisterHandlerで
+	// HTTP/2・HTTP/3双方のハンドラをドレイン対象として登録できるようにする）
+	shutdown := &GracefulShutdown{
+		HealthChecker: healthChecker,
+		GRPCHealth:    grpcHealth,
+		Logger:        logger,
+		Timeout:       30 * time.Second,
+	}
+	// RequestIDMiddleware wraps the drain-tracking handler so every log line
+	// emitted while serving a request (gRPC or plain HTTP) carries the same
+	// request_id, whether it arrived over HTTP/2 or HTTP/3.
+	drainedGRPC := common.RequestIDMiddleware(logger, shutdown.RegisterHandler(grpcServer))
+
+	// HTTP/2 サーバー（Alt-Svcでhttp3エンドポイントを告知）
 	http2Server := &http.Server{
-		Addr:    fmt.Sprintf(":%d", config.ServerPort),
-		Handler: grpcServer,
+		Addr:      fmt.Sprintf(":%d", config.ServerPort),
+		Handler:   altSvcMiddleware(drainedGRPC, config.HTTP3Port),
+		TLSConfig: tlsConfig,
 	}
 
 	// HTTP/2設定
@@ -69,38 +187,45 @@ func main() {
 		log.Fatalf("Failed to configure HTTP/2 server: %v", err)
 	}
 
-	// HTTP/3 サーバー（一旦無効化 - TLS必須のため）
-	// http3Server := &http3.Server{
-	// 	Addr:      fmt.Sprintf(":%d", config.HTTP3Port),
-	// 	Handler:   grpcServer,
-	// 	TLSConfig: tlsConfig,
-	// }
+	// HTTP/3 サーバー（同じgRPCハンドラを共有、ドレイン対象として同じラッパーを使う）
+	//
+	// config.RequireAddressValidationはまだここに配線されていない。quic-go
+	// はRetryをquic.Configのフィールドとしては公開しておらず、VerifySourceAddress
+	// を設定したquic.Transportでリスナーを自前駆動する場合にのみ強制できる
+	// - ListenAndServeで自動リスンするhttp3.Serverではその経路を使えない
+	// (see common.Config.RequireAddressValidation)。
+	if config.RequireAddressValidation {
+		logger.Warn("require_address_validation is set but not enforced by this server yet; results will not actually pay QUIC's Retry round trip")
+	}
+	http3Server := &http3.Server{
+		Addr:      fmt.Sprintf(":%d", config.HTTP3Port),
+		Handler:   drainedGRPC,
+		TLSConfig: tlsConfig,
+	}
 
 	// サーバー起動
 	go func() {
 		logger.Info("Starting HTTP/2 server", "port", config.ServerPort)
-		if err := http2Server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		if err := http2Server.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("HTTP/2 server failed: %v", err)
 		}
 	}()
 
-	// HTTP/3 サーバー起動（一旦無効化）
-	// go func() {
-	// 	logger.Info("Starting HTTP/3 server", "port", config.HTTP3Port)
-	// 	if err := http3Server.ListenAndServe(); err != nil {
-	// 		log.Fatalf("HTTP/3 server failed: %v", err)
-	// 	}
-	// }()
+	// HTTP/3 サーバー起動
+	go func() {
+		logger.Info("Starting HTTP/3 server", "port", config.HTTP3Port)
+		if err := http3Server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("HTTP/3 server failed: %v", err)
+		}
+	}()
 
 	// 準備完了
 	healthChecker.SetReady(true)
 	logger.Info("Server is ready")
 
-	// グレースフルシャットダウン
-	shutdown := &GracefulShutdown{
-		Server:      http2Server,
-		HTTP3Server: nil, // HTTP/3は無効化
-		Timeout:     30 * time.Second,
-	}
+	// グレースフルシャットダウン（Server/HTTP3Serverはリスナー起動後に判明するため、
+	// 先に構築したshutdownへここで設定する）
+	shutdown.Server = http2Server
+	shutdown.HTTP3Server = http3Server
 	shutdown.WaitForShutdown()
 }
@@ -0,0 +1,37 @@
+package stats
+
+import (
+	"math/rand"
+	"sort"
+)
+
+// BootstrapMeanDiffCI resamples a and b with replacement `iterations` times,
+// computes mean(b_resample) - mean(a_resample) each time, and returns the
+// 2.5th/97.5th percentiles of that distribution as a 95% confidence
+// interval on the difference of means.
+func BootstrapMeanDiffCI(a, b []float64, iterations int) (lower, upper float64) {
+	if len(a) == 0 || len(b) == 0 || iterations <= 0 {
+		return 0, 0
+	}
+
+	diffs := make([]float64, iterations)
+	for i := 0; i < iterations; i++ {
+		diffs[i] = Mean(resample(b)) - Mean(resample(a))
+	}
+
+	sort.Float64s(diffs)
+	lowerIdx := int(0.025 * float64(len(diffs)))
+	upperIdx := int(0.975 * float64(len(diffs)))
+	if upperIdx >= len(diffs) {
+		upperIdx = len(diffs) - 1
+	}
+	return diffs[lowerIdx], diffs[upperIdx]
+}
+
+func resample(values []float64) []float64 {
+	out := make([]float64, len(values))
+	for i := range out {
+		out[i] = values[rand.Intn(len(values))]
+	}
+	return out
+}
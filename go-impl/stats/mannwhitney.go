@@ -0,0 +1,77 @@
+package stats
+
+import (
+	"math"
+	"sort"
+)
+
+// MannWhitneyU runs a one-sided Mann-Whitney U test for the alternative
+// hypothesis that samples in `faster` tend to be smaller (i.e. lower
+// latency) than samples in `slower`, and returns the U statistic and the
+// corresponding p-value using the normal approximation (valid for the
+// sample sizes this analyzer deals with, a handful to a few dozen runs).
+func MannWhitneyU(faster, slower []float64) (u, pValue float64) {
+	n1, n2 := len(faster), len(slower)
+	if n1 == 0 || n2 == 0 {
+		return 0, 1
+	}
+
+	type labeled struct {
+		value float64
+		group int // 0 = faster, 1 = slower
+	}
+
+	combined := make([]labeled, 0, n1+n2)
+	for _, v := range faster {
+		combined = append(combined, labeled{v, 0})
+	}
+	for _, v := range slower {
+		combined = append(combined, labeled{v, 1})
+	}
+	sort.Slice(combined, func(i, j int) bool { return combined[i].value < combined[j].value })
+
+	ranks := make([]float64, len(combined))
+	i := 0
+	for i < len(combined) {
+		j := i
+		for j < len(combined) && combined[j].value == combined[i].value {
+			j++
+		}
+		avgRank := float64(i+j+1) / 2 // average rank for tied values, 1-indexed
+		for k := i; k < j; k++ {
+			ranks[k] = avgRank
+		}
+		i = j
+	}
+
+	var rankSumFaster float64
+	for i, c := range combined {
+		if c.group == 0 {
+			rankSumFaster += ranks[i]
+		}
+	}
+
+	uFaster := rankSumFaster - float64(n1*(n1+1))/2
+	uOther := float64(n1*n2) - uFaster
+
+	// U is conventionally the smaller of the two, but here we want "is
+	// faster actually faster" so we keep uFaster explicitly.
+	u = uFaster
+
+	meanU := float64(n1*n2) / 2
+	stdDevU := math.Sqrt(float64(n1*n2*(n1+n2+1)) / 12)
+	if stdDevU == 0 {
+		return u, 1
+	}
+
+	z := (uFaster - meanU) / stdDevU
+	pValue = normalCDF(z)
+	_ = uOther
+	return u, pValue
+}
+
+// normalCDF is the standard normal cumulative distribution function, used to
+// turn the Mann-Whitney z-score into a one-sided p-value.
+func normalCDF(z float64) float64 {
+	return 0.5 * math.Erfc(-z/math.Sqrt2)
+}
@@ -0,0 +1,94 @@
+// Package stats provides numerically stable descriptive statistics and
+// hypothesis tests for comparing HTTP/2 and HTTP/3 latency samples across
+// multiple benchmark runs.
+package stats
+
+import "math"
+
+// VarianceAccumulator computes mean and variance online using Welford's
+// algorithm, avoiding the catastrophic cancellation of the naive
+// sum-of-squares approach used by the original analyzer.
+type VarianceAccumulator struct {
+	count int
+	mean  float64
+	m2    float64
+}
+
+// Add folds a single observation into the accumulator.
+func (v *VarianceAccumulator) Add(x float64) {
+	v.count++
+	delta := x - v.mean
+	v.mean += delta / float64(v.count)
+	delta2 := x - v.mean
+	v.m2 += delta * delta2
+}
+
+// Count returns the number of observations folded in so far.
+func (v *VarianceAccumulator) Count() int {
+	return v.count
+}
+
+// Mean returns the running mean.
+func (v *VarianceAccumulator) Mean() float64 {
+	return v.mean
+}
+
+// Variance returns the sample variance (Bessel's correction applied).
+func (v *VarianceAccumulator) Variance() float64 {
+	if v.count < 2 {
+		return 0
+	}
+	return v.m2 / float64(v.count-1)
+}
+
+// StdDev returns the sample standard deviation.
+func (v *VarianceAccumulator) StdDev() float64 {
+	return math.Sqrt(v.Variance())
+}
+
+// Mean computes the arithmetic mean of values.
+func Mean(values []float64) float64 {
+	var acc VarianceAccumulator
+	for _, v := range values {
+		acc.Add(v)
+	}
+	return acc.Mean()
+}
+
+// StdDev computes the sample standard deviation of values using Welford's
+// algorithm.
+func StdDev(values []float64) float64 {
+	var acc VarianceAccumulator
+	for _, v := range values {
+		acc.Add(v)
+	}
+	return acc.StdDev()
+}
+
+// Min returns the smallest value, or 0 for an empty slice.
+func Min(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
+
+// Max returns the largest value, or 0 for an empty slice.
+func Max(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	m := values[0]
+	for _, v := range values[1:] {
+		if v > m {
+			m = v
+		}
+	}
+	return m
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"grpc-over-http3/common"
+	pb "grpc-over-http3/proto"
+)
+
+// streamSample is one message observed on one of the concurrent streams
+// opened by runStreamLatencyTest.
+type streamSample struct {
+	streamID       int
+	firstByte      time.Duration // time from stream open to first response
+	interMessage   time.Duration // gap since the previous response on this stream
+	blockedByStall bool          // true if this message arrived while the stalled sibling was stalling
+}
+
+// runStreamLatencyTest opens streamCount concurrent BidiStream RPCs on a
+// single connection, deliberately stalls one of them (stallStreamIdx) for
+// stallFor before letting it resume, and records whether the other streams'
+// messages were delayed by the stall. This is where HTTP/3's independently
+// multiplexed streams should outperform HTTP/2's single TCP connection,
+// where one stalled stream can block the rest (head-of-line blocking).
+func runStreamLatencyTest(protocol, addr string, streamCount int, messagesPerStream int, stallStreamIdx int, stallFor time.Duration) LatencyResult {
+	logger := common.NewLogger("INFO")
+	logger.Info("Starting stream latency test", "protocol", protocol, "streams", streamCount)
+
+	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithBlock())
+	if err != nil {
+		logger.Error("Failed to dial server", "error", err)
+		return LatencyResult{Protocol: protocol, StreamCount: streamCount}
+	}
+	defer conn.Close()
+
+	client := pb.NewEchoServiceClient(conn)
+
+	var mu sync.Mutex
+	var samples []streamSample
+	var wg sync.WaitGroup
+
+	stallStart := time.Now().Add(50 * time.Millisecond) // give every stream time to open first
+	stallEnd := stallStart.Add(stallFor)
+
+	for i := 0; i < streamCount; i++ {
+		wg.Add(1)
+		go func(streamID int) {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+
+			stream, err := client.BidiStream(ctx)
+			if err != nil {
+				logger.Error("Failed to open stream", "stream", streamID, "error", err)
+				return
+			}
+
+			openedAt := time.Now()
+			lastMessageAt := openedAt
+			var firstByte time.Duration
+
+			for m := 0; m < messagesPerStream; m++ {
+				if streamID == stallStreamIdx && time.Now().Before(stallEnd) && time.Now().After(stallStart) {
+					time.Sleep(time.Until(stallEnd))
+				}
+
+				if err := stream.Send(&pb.EchoRequest{
+					Message:   fmt.Sprintf("stream-%d-msg-%d", streamID, m),
+					Timestamp: time.Now().UnixNano(),
+				}); err != nil {
+					return
+				}
+
+				if _, err := stream.Recv(); err != nil {
+					return
+				}
+
+				now := time.Now()
+				if m == 0 {
+					firstByte = now.Sub(openedAt)
+				}
+				blocked := streamID != stallStreamIdx && now.After(stallStart) && now.Before(stallEnd)
+
+				mu.Lock()
+				samples = append(samples, streamSample{
+					streamID:       streamID,
+					firstByte:      firstByte,
+					interMessage:   now.Sub(lastMessageAt),
+					blockedByStall: blocked,
+				})
+				mu.Unlock()
+
+				lastMessageAt = now
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	return summarizeStreamSamples(protocol, streamCount, samples)
+}
+
+func summarizeStreamSamples(protocol string, streamCount int, samples []streamSample) LatencyResult {
+	if len(samples) == 0 {
+		return LatencyResult{Protocol: protocol, StreamCount: streamCount}
+	}
+
+	interMessages := make([]time.Duration, len(samples))
+	var blocked int
+	for i, s := range samples {
+		interMessages[i] = s.interMessage
+		if s.blockedByStall {
+			blocked++
+		}
+	}
+
+	sort.Slice(interMessages, func(i, j int) bool { return interMessages[i] < interMessages[j] })
+	p95Index := int(float64(len(interMessages)) * 0.95)
+	if p95Index >= len(interMessages) {
+		p95Index = len(interMessages) - 1
+	}
+
+	return LatencyResult{
+		Protocol:        protocol,
+		Requests:        len(samples),
+		Successes:       len(samples),
+		StreamCount:     streamCount,
+		InterMessageP95: interMessages[p95Index],
+		BlockedRatio:    float64(blocked) / float64(len(samples)),
+	}
+}
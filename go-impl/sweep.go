@@ -0,0 +1,161 @@
+package main
+
+import (
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Impairment mirrors router/network_emulation.go's Impairment - the two
+// can't share a Go type since each lives in its own package main, but the
+// JSON field names must match what the router's /network/config expects.
+type Impairment struct {
+	DelayMs         int     `json:"delay_ms" yaml:"delay_ms"`
+	JitterMs        int     `json:"jitter_ms" yaml:"jitter_ms"`
+	JitterDist      string  `json:"jitter_distribution" yaml:"jitter_distribution"`
+	LossPct         float64 `json:"loss_pct" yaml:"loss_pct"`
+	LossCorrelation float64 `json:"loss_correlation" yaml:"loss_correlation"`
+	DuplicatePct    float64 `json:"duplicate_pct" yaml:"duplicate_pct"`
+	ReorderPct      float64 `json:"reorder_pct" yaml:"reorder_pct"`
+	CorruptPct      float64 `json:"corrupt_pct" yaml:"corrupt_pct"`
+	BandwidthKbps   int     `json:"bandwidth_kbps" yaml:"bandwidth_kbps"`
+	QueueLimit      int     `json:"queue_limit" yaml:"queue_limit"`
+}
+
+// NetworkConfigRequest mirrors router/router.go's NetworkConfigRequest.
+type NetworkConfigRequest struct {
+	Up         Impairment `json:"up"`
+	Down       Impairment `json:"down"`
+	Asymmetric bool       `json:"asymmetric"`
+}
+
+// ImpairmentAxes is one value-list per impairment dimension; SweepConfig's
+// cartesian product runs every combination across all axes.
+type ImpairmentAxes struct {
+	DelayMs         []int     `yaml:"delay_ms"`
+	JitterMs        []int     `yaml:"jitter_ms"`
+	JitterDist      []string  `yaml:"jitter_distribution"`
+	LossPct         []float64 `yaml:"loss_pct"`
+	LossCorrelation []float64 `yaml:"loss_correlation"`
+	DuplicatePct    []float64 `yaml:"duplicate_pct"`
+	ReorderPct      []float64 `yaml:"reorder_pct"`
+	CorruptPct      []float64 `yaml:"corrupt_pct"`
+	BandwidthKbps   []int     `yaml:"bandwidth_kbps"`
+	QueueLimit      []int     `yaml:"queue_limit"`
+}
+
+// SweepConfig is the top-level YAML shape for --sweep-config. Down/Asymmetric
+// describe a fixed (non-swept) downstream impairment applied alongside each
+// upstream point in the Up axes' cartesian product.
+type SweepConfig struct {
+	Up         ImpairmentAxes `yaml:"up"`
+	Down       Impairment     `yaml:"down"`
+	Asymmetric bool           `yaml:"asymmetric"`
+}
+
+// defaultSweepConfig reproduces the benchmark's original behavior - four
+// delay points, no jitter/loss/reordering/bandwidth cap - for runs that don't
+// pass --sweep-config.
+func defaultSweepConfig() SweepConfig {
+	return SweepConfig{
+		Up: ImpairmentAxes{
+			DelayMs: []int{0, 75, 150, 225},
+		},
+	}
+}
+
+// loadSweepConfig reads a YAML sweep declaration, or returns
+// defaultSweepConfig if path is empty.
+func loadSweepConfig(path string) (SweepConfig, error) {
+	if path == "" {
+		return defaultSweepConfig(), nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return SweepConfig{}, err
+	}
+
+	var cfg SweepConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return SweepConfig{}, err
+	}
+	return cfg, nil
+}
+
+// normalize fills any empty axis with its zero value so it still
+// participates in the product instead of collapsing it to nothing.
+func (a ImpairmentAxes) normalize() ImpairmentAxes {
+	if len(a.DelayMs) == 0 {
+		a.DelayMs = []int{0}
+	}
+	if len(a.JitterMs) == 0 {
+		a.JitterMs = []int{0}
+	}
+	if len(a.JitterDist) == 0 {
+		a.JitterDist = []string{""}
+	}
+	if len(a.LossPct) == 0 {
+		a.LossPct = []float64{0}
+	}
+	if len(a.LossCorrelation) == 0 {
+		a.LossCorrelation = []float64{0}
+	}
+	if len(a.DuplicatePct) == 0 {
+		a.DuplicatePct = []float64{0}
+	}
+	if len(a.ReorderPct) == 0 {
+		a.ReorderPct = []float64{0}
+	}
+	if len(a.CorruptPct) == 0 {
+		a.CorruptPct = []float64{0}
+	}
+	if len(a.BandwidthKbps) == 0 {
+		a.BandwidthKbps = []int{0}
+	}
+	if len(a.QueueLimit) == 0 {
+		a.QueueLimit = []int{0}
+	}
+	return a
+}
+
+// product returns the cartesian product of every axis as one Impairment per
+// combination.
+func (a ImpairmentAxes) product() []Impairment {
+	a = a.normalize()
+
+	var result []Impairment
+	for _, delayMs := range a.DelayMs {
+		for _, jitterMs := range a.JitterMs {
+			for _, jitterDist := range a.JitterDist {
+				for _, lossPct := range a.LossPct {
+					for _, lossCorrelation := range a.LossCorrelation {
+						for _, duplicatePct := range a.DuplicatePct {
+							for _, reorderPct := range a.ReorderPct {
+								for _, corruptPct := range a.CorruptPct {
+									for _, bandwidthKbps := range a.BandwidthKbps {
+										for _, queueLimit := range a.QueueLimit {
+											result = append(result, Impairment{
+												DelayMs:         delayMs,
+												JitterMs:        jitterMs,
+												JitterDist:      jitterDist,
+												LossPct:         lossPct,
+												LossCorrelation: lossCorrelation,
+												DuplicatePct:    duplicatePct,
+												ReorderPct:      reorderPct,
+												CorruptPct:      corruptPct,
+												BandwidthKbps:   bandwidthKbps,
+												QueueLimit:      queueLimit,
+											})
+										}
+									}
+								}
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+	return result
+}
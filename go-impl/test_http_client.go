@@ -2,6 +2,7 @@ package main
 
 import (
 	"crypto/tls"
+	"fmt"
 	"io"
 	"net/http"
 	"time"
@@ -24,6 +25,10 @@ func main() {
 	// HTTP/3 テスト
 	logger.Info("Testing HTTP/3 connectivity...")
 	testHTTP3(logger)
+
+	// Alt-Svc経由の自動アップグレードテスト
+	logger.Info("Testing Alt-Svc driven HTTP/2->HTTP/3 upgrade...")
+	testAltSvcUpgrade(logger)
 }
 
 func testHTTP2(logger *common.Logger) {
@@ -127,3 +132,68 @@ func testHTTP3(logger *common.Logger) {
 
 	logger.Info("HTTP/3 test completed successfully")
 }
+
+// testAltSvcUpgrade issues a first request over plain HTTP/2, caches any h3
+// Alt-Svc advertisement the response carries in a common.AltSvcCache, and
+// then issues a second request that transparently switches to an
+// http3.RoundTripper for the same origin if an unexpired advertisement was
+// found - the same upgrade path a real browser follows, rather than
+// testHTTP2/testHTTP3's hardcoded ports above.
+func testAltSvcUpgrade(logger *common.Logger) {
+	const (
+		host      = "172.31.0.2"
+		http2Port = 443
+	)
+	origin := common.Origin(host, http2Port)
+	cache := common.NewAltSvcCache()
+
+	http2Client := &http.Client{Timeout: 10 * time.Second}
+
+	resp, err := http2Client.Get("http://172.31.0.2:443/health")
+	if err != nil {
+		logger.Error("Alt-Svc probe over HTTP/2 failed", "error", err)
+		return
+	}
+	altSvcHeader := resp.Header.Get("Alt-Svc")
+	resp.Body.Close()
+
+	if altSvcHeader != "" {
+		cache.Observe(origin, altSvcHeader)
+	}
+
+	h3Port, upgraded := cache.Lookup(origin)
+	if !upgraded {
+		logger.Info("No Alt-Svc advertisement seen; staying on HTTP/2")
+		return
+	}
+
+	logger.Info("Alt-Svc advertised HTTP/3; upgrading subsequent requests", "port", h3Port)
+
+	h3Client := &http.Client{
+		Transport: &http3.RoundTripper{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+		Timeout: 10 * time.Second,
+	}
+
+	start := time.Now()
+	resp, err = h3Client.Get(fmt.Sprintf("https://%s:%d/health", host, h3Port))
+	if err != nil {
+		logger.Error("Upgraded HTTP/3 request failed", "error", err)
+		return
+	}
+	defer resp.Body.Close()
+	upgradeLatency := time.Since(start)
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		logger.Error("Failed to read upgraded response", "error", err)
+		return
+	}
+
+	logger.Info("Upgraded response",
+		"status", resp.Status,
+		"protocol", resp.Proto,
+		"upgrade_latency", upgradeLatency,
+		"body", string(body))
+}
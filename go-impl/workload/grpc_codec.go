@@ -0,0 +1,31 @@
+package workload
+
+// bytesCodec is the same pass-through codec idea as proxy/codec.go's
+// rawCodec: with no generated pb stubs in this tree, calls carry pre-encoded
+// wire bytes directly rather than a struct grpc would marshal itself.
+type bytesCodec struct{}
+
+func (bytesCodec) Marshal(v interface{}) ([]byte, error) {
+	b, ok := v.([]byte)
+	if !ok {
+		return nil, errNotBytes
+	}
+	return b, nil
+}
+
+func (bytesCodec) Unmarshal(data []byte, v interface{}) error {
+	out, ok := v.(*[]byte)
+	if !ok {
+		return errNotBytes
+	}
+	*out = append([]byte(nil), data...)
+	return nil
+}
+
+func (bytesCodec) Name() string { return "raw" }
+
+type codecError string
+
+func (e codecError) Error() string { return string(e) }
+
+const errNotBytes = codecError("workload: bytesCodec only accepts []byte")
@@ -0,0 +1,183 @@
+package workload
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+const (
+	echoMethod         = "/echo.EchoService/Echo"
+	serverStreamMethod = "/echo.EchoService/ServerStream"
+	bidiStreamMethod   = "/echo.EchoService/BidiStream"
+)
+
+// newPayload builds an EchoRequest of roughly payloadSize bytes by padding
+// the message field - there's no dedicated payload field in echo.proto, so
+// this is the same trick benchmark/worker.go's makePayload uses.
+func newPayload(payloadSize int) []byte {
+	message := make([]byte, payloadSize)
+	for i := range message {
+		message[i] = 'x'
+	}
+	return encodeEchoRequest(string(message), time.Now().UnixNano())
+}
+
+// GRPCUnaryWorkload calls EchoService.Echo once per Do, over a real
+// *grpc.ClientConn (HTTP/2 transport).
+type GRPCUnaryWorkload struct {
+	Conn        *grpc.ClientConn
+	PayloadSize int
+}
+
+func (w *GRPCUnaryWorkload) Do(ctx context.Context) (Metrics, error) {
+	start := time.Now()
+
+	req := newPayload(w.PayloadSize)
+	var respBytes []byte
+	if err := w.Conn.Invoke(ctx, echoMethod, req, &respBytes, grpc.ForceCodec(bytesCodec{})); err != nil {
+		return Metrics{}, fmt.Errorf("workload: unary call failed: %v", err)
+	}
+	if _, err := decodeEchoResponse(respBytes); err != nil {
+		return Metrics{}, err
+	}
+
+	return Metrics{Latency: time.Since(start), Messages: 1}, nil
+}
+
+// GRPCServerStreamWorkload sends one EchoRequest to EchoService.ServerStream,
+// reads MessageCount response messages (at least 1) and then cancels the
+// call, since the server keeps streaming until the client gives up. It
+// records time-to-first-byte and the gap between consecutive messages.
+type GRPCServerStreamWorkload struct {
+	Conn         *grpc.ClientConn
+	PayloadSize  int
+	MessageCount int // how many response messages to read before canceling the call
+}
+
+func (w *GRPCServerStreamWorkload) Do(ctx context.Context) (Metrics, error) {
+	start := time.Now()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	count := w.MessageCount
+	if count < 1 {
+		count = 1
+	}
+
+	stream, err := w.Conn.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true}, serverStreamMethod, grpc.ForceCodec(bytesCodec{}))
+	if err != nil {
+		return Metrics{}, fmt.Errorf("workload: failed to open server stream: %v", err)
+	}
+
+	if err := stream.SendMsg(newPayload(w.PayloadSize)); err != nil {
+		return Metrics{}, fmt.Errorf("workload: failed to send request: %v", err)
+	}
+	if err := stream.CloseSend(); err != nil {
+		return Metrics{}, fmt.Errorf("workload: failed to close send: %v", err)
+	}
+
+	metrics, err := drainStream(stream, count)
+	if err != nil {
+		return Metrics{}, err
+	}
+	metrics.Latency = time.Since(start)
+	return metrics, nil
+}
+
+// GRPCBidiStreamWorkload runs a ping-pong exchange over EchoService.BidiStream:
+// send a message, wait for its echo, send the next. This gives inter-message
+// latency a direct request/response meaning rather than a one-sided fan-out.
+type GRPCBidiStreamWorkload struct {
+	Conn         *grpc.ClientConn
+	PayloadSize  int
+	MessageCount int
+}
+
+func (w *GRPCBidiStreamWorkload) Do(ctx context.Context) (Metrics, error) {
+	start := time.Now()
+
+	stream, err := w.Conn.NewStream(ctx, &grpc.StreamDesc{ClientStreams: true, ServerStreams: true}, bidiStreamMethod, grpc.ForceCodec(bytesCodec{}))
+	if err != nil {
+		return Metrics{}, fmt.Errorf("workload: failed to open bidi stream: %v", err)
+	}
+
+	count := w.MessageCount
+	if count < 1 {
+		count = 1
+	}
+
+	var lastRecv time.Time
+	var firstByte time.Duration
+	var interMessage []time.Duration
+
+	for i := 0; i < count; i++ {
+		if err := stream.SendMsg(newPayload(w.PayloadSize)); err != nil {
+			return Metrics{}, fmt.Errorf("workload: failed to send message %d: %v", i+1, err)
+		}
+
+		var respBytes []byte
+		if err := stream.RecvMsg(&respBytes); err != nil {
+			return Metrics{}, fmt.Errorf("workload: failed to receive message %d: %v", i+1, err)
+		}
+
+		now := time.Now()
+		if i == 0 {
+			firstByte = now.Sub(start)
+		} else {
+			interMessage = append(interMessage, now.Sub(lastRecv))
+		}
+		lastRecv = now
+	}
+
+	if err := stream.CloseSend(); err != nil {
+		return Metrics{}, fmt.Errorf("workload: failed to close send: %v", err)
+	}
+
+	return Metrics{
+		Latency:         time.Since(start),
+		TimeToFirstByte: firstByte,
+		InterMessage:    interMessage,
+		Messages:        count,
+	}, nil
+}
+
+// drainStream reads up to limit response messages from a server-streaming
+// gRPC stream, stopping early at io.EOF, and records time-to-first-byte and
+// inter-message gaps.
+func drainStream(stream grpc.ClientStream, limit int) (Metrics, error) {
+	var count int
+	var firstByte time.Duration
+	var interMessage []time.Duration
+	var lastRecv, start time.Time
+	start = time.Now()
+
+	for count < limit {
+		var respBytes []byte
+		err := stream.RecvMsg(&respBytes)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return Metrics{}, fmt.Errorf("workload: stream recv failed: %v", err)
+		}
+
+		now := time.Now()
+		count++
+		if count == 1 {
+			firstByte = now.Sub(start)
+		} else {
+			interMessage = append(interMessage, now.Sub(lastRecv))
+		}
+		lastRecv = now
+	}
+
+	return Metrics{
+		TimeToFirstByte: firstByte,
+		InterMessage:    interMessage,
+		Messages:        count,
+	}, nil
+}
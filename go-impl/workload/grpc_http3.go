@@ -0,0 +1,212 @@
+package workload
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// grpc-go has no QUIC transport, so HTTP/3 calls here hand-frame gRPC's wire
+// format (a 1-byte compressed flag plus a 4-byte big-endian length prefix per
+// message) onto an http3.RoundTripper-backed *http.Client, the same way
+// server.go already serves the grpc.Server's handler over http3.Server as a
+// plain http.Handler. This is the manual equivalent of what grpc-go's HTTP/2
+// transport does internally.
+
+func writeGRPCFrame(w io.Writer, payload []byte) error {
+	header := make([]byte, 5)
+	binary.BigEndian.PutUint32(header[1:], uint32(len(payload)))
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(payload)
+	return err
+}
+
+func readGRPCFrame(r io.Reader) ([]byte, error) {
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	length := binary.BigEndian.Uint32(header[1:])
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, fmt.Errorf("workload: truncated grpc frame: %v", err)
+	}
+	return payload, nil
+}
+
+// GRPCOverHTTP3UnaryWorkload calls EchoService.Echo once per Do, framing the
+// request/response by hand over an HTTP/3 RoundTripper.
+type GRPCOverHTTP3UnaryWorkload struct {
+	Client      *http.Client // must use an http3.RoundTripper transport
+	BaseURL     string       // e.g. "https://host:port"
+	PayloadSize int
+}
+
+func (w *GRPCOverHTTP3UnaryWorkload) Do(ctx context.Context) (Metrics, error) {
+	start := time.Now()
+
+	var body bytes.Buffer
+	if err := writeGRPCFrame(&body, newPayload(w.PayloadSize)); err != nil {
+		return Metrics{}, fmt.Errorf("workload: failed to frame request: %v", err)
+	}
+
+	resp, err := w.post(ctx, echoMethod, &body)
+	if err != nil {
+		return Metrics{}, err
+	}
+	defer resp.Body.Close()
+
+	respPayload, err := readGRPCFrame(resp.Body)
+	if err != nil {
+		return Metrics{}, fmt.Errorf("workload: failed to read response: %v", err)
+	}
+	if _, err := decodeEchoResponse(respPayload); err != nil {
+		return Metrics{}, err
+	}
+
+	return Metrics{Latency: time.Since(start), Messages: 1}, nil
+}
+
+func (w *GRPCOverHTTP3UnaryWorkload) post(ctx context.Context, method string, body io.Reader) (*http.Response, error) {
+	return doGRPCOverHTTP3(ctx, w.Client, w.BaseURL, method, body)
+}
+
+func doGRPCOverHTTP3(ctx context.Context, client *http.Client, baseURL, method string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, baseURL+method, body)
+	if err != nil {
+		return nil, fmt.Errorf("workload: failed to build request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("TE", "trailers")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// GRPCOverHTTP3ServerStreamWorkload sends one EchoRequest to
+// EchoService.ServerStream and reads every framed response message off the
+// same HTTP/3 response body, recording time-to-first-byte and inter-message
+// gaps.
+type GRPCOverHTTP3ServerStreamWorkload struct {
+	Client      *http.Client
+	BaseURL     string
+	PayloadSize int
+}
+
+func (w *GRPCOverHTTP3ServerStreamWorkload) Do(ctx context.Context) (Metrics, error) {
+	start := time.Now()
+
+	var body bytes.Buffer
+	if err := writeGRPCFrame(&body, newPayload(w.PayloadSize)); err != nil {
+		return Metrics{}, fmt.Errorf("workload: failed to frame request: %v", err)
+	}
+
+	resp, err := doGRPCOverHTTP3(ctx, w.Client, w.BaseURL, serverStreamMethod, &body)
+	if err != nil {
+		return Metrics{}, err
+	}
+	defer resp.Body.Close()
+
+	metrics, err := drainGRPCFrames(resp.Body, start)
+	if err != nil {
+		return Metrics{}, err
+	}
+	metrics.Latency = time.Since(start)
+	return metrics, nil
+}
+
+// GRPCOverHTTP3BidiStreamWorkload pipelines MessageCount request frames onto
+// a streaming request body while concurrently reading response frames off
+// the same HTTP/3 exchange, exercising independent-stream behavior the same
+// way the HTTP/2 bidi workload does, just without grpc-go's framing.
+type GRPCOverHTTP3BidiStreamWorkload struct {
+	Client       *http.Client
+	BaseURL      string
+	PayloadSize  int
+	MessageCount int
+}
+
+func (w *GRPCOverHTTP3BidiStreamWorkload) Do(ctx context.Context) (Metrics, error) {
+	start := time.Now()
+
+	count := w.MessageCount
+	if count < 1 {
+		count = 1
+	}
+
+	pr, pw := io.Pipe()
+	writeErr := make(chan error, 1)
+	go func() {
+		defer pw.Close()
+		for i := 0; i < count; i++ {
+			if err := writeGRPCFrame(pw, newPayload(w.PayloadSize)); err != nil {
+				writeErr <- err
+				return
+			}
+		}
+		writeErr <- nil
+	}()
+
+	resp, err := doGRPCOverHTTP3(ctx, w.Client, w.BaseURL, bidiStreamMethod, pr)
+	if err != nil {
+		return Metrics{}, err
+	}
+	defer resp.Body.Close()
+
+	metrics, recvErr := drainGRPCFrames(resp.Body, start)
+	if sendErr := <-writeErr; sendErr != nil && recvErr == nil {
+		return Metrics{}, fmt.Errorf("workload: failed to send message: %v", sendErr)
+	}
+	if recvErr != nil {
+		return Metrics{}, recvErr
+	}
+
+	metrics.Latency = time.Since(start)
+	return metrics, nil
+}
+
+// drainGRPCFrames reads hand-framed response messages from r until EOF,
+// recording time-to-first-byte (relative to start) and inter-message gaps.
+func drainGRPCFrames(r io.Reader, start time.Time) (Metrics, error) {
+	var count int
+	var firstByte time.Duration
+	var interMessage []time.Duration
+	var lastRecv time.Time
+
+	for {
+		payload, err := readGRPCFrame(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return Metrics{}, err
+		}
+		if _, err := decodeEchoResponse(payload); err != nil {
+			return Metrics{}, err
+		}
+
+		now := time.Now()
+		count++
+		if count == 1 {
+			firstByte = now.Sub(start)
+		} else {
+			interMessage = append(interMessage, now.Sub(lastRecv))
+		}
+		lastRecv = now
+	}
+
+	return Metrics{
+		TimeToFirstByte: firstByte,
+		InterMessage:    interMessage,
+		Messages:        count,
+	}, nil
+}
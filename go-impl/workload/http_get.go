@@ -0,0 +1,38 @@
+package workload
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// HTTPGetWorkload is a plain GET probe - the harness's original /health
+// check, wrapped to satisfy Workload so it can run alongside the gRPC
+// workloads under the same Runner.
+type HTTPGetWorkload struct {
+	Client *http.Client
+	URL    string
+}
+
+func (w *HTTPGetWorkload) Do(ctx context.Context) (Metrics, error) {
+	start := time.Now()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, w.URL, nil)
+	if err != nil {
+		return Metrics{}, fmt.Errorf("workload: failed to build request: %v", err)
+	}
+
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return Metrics{}, err
+	}
+	defer resp.Body.Close()
+
+	if _, err := io.ReadAll(resp.Body); err != nil {
+		return Metrics{}, err
+	}
+
+	return Metrics{Latency: time.Since(start), Messages: 1}, nil
+}
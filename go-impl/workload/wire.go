@@ -0,0 +1,99 @@
+package workload
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// This file hand-encodes the small subset of proto/echo.proto's wire format
+// this package needs (EchoRequest{message string=1; timestamp int64=2} and
+// EchoResponse{message string=1; timestamp int64=2; protocol string=3}).
+// There are no generated pb stubs in this tree, so workloads that want to
+// exercise the real EchoService wire
+// format have to build it by hand, the same way benchmark/worker.go and
+// proxy/codec.go already do for their raw frames.
+
+const (
+	wireVarint = 0
+	wireBytes  = 2
+)
+
+func appendTag(buf []byte, fieldNum, wireType int) []byte {
+	return binary.AppendUvarint(buf, uint64(fieldNum)<<3|uint64(wireType))
+}
+
+func appendString(buf []byte, fieldNum int, s string) []byte {
+	buf = appendTag(buf, fieldNum, wireBytes)
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+func appendVarint(buf []byte, fieldNum int, v int64) []byte {
+	buf = appendTag(buf, fieldNum, wireVarint)
+	return binary.AppendUvarint(buf, uint64(v))
+}
+
+// encodeEchoRequest builds the wire bytes for an EchoRequest.
+func encodeEchoRequest(message string, timestamp int64) []byte {
+	var buf []byte
+	buf = appendString(buf, 1, message)
+	buf = appendVarint(buf, 2, timestamp)
+	return buf
+}
+
+// echoResponse is the decoded shape of EchoResponse.
+type echoResponse struct {
+	Message   string
+	Timestamp int64
+	Protocol  string
+}
+
+// decodeEchoResponse parses wire bytes produced by the server's EchoResponse,
+// skipping any field this package doesn't care about.
+func decodeEchoResponse(data []byte) (echoResponse, error) {
+	var resp echoResponse
+
+	for len(data) > 0 {
+		tag, n := binary.Uvarint(data)
+		if n <= 0 {
+			return resp, fmt.Errorf("workload: malformed tag")
+		}
+		data = data[n:]
+
+		fieldNum := int(tag >> 3)
+		wireType := int(tag & 0x7)
+
+		switch wireType {
+		case wireVarint:
+			v, n := binary.Uvarint(data)
+			if n <= 0 {
+				return resp, fmt.Errorf("workload: malformed varint field %d", fieldNum)
+			}
+			data = data[n:]
+			if fieldNum == 2 {
+				resp.Timestamp = int64(v)
+			}
+		case wireBytes:
+			length, n := binary.Uvarint(data)
+			if n <= 0 {
+				return resp, fmt.Errorf("workload: malformed length field %d", fieldNum)
+			}
+			data = data[n:]
+			if uint64(len(data)) < length {
+				return resp, fmt.Errorf("workload: truncated field %d", fieldNum)
+			}
+			value := string(data[:length])
+			data = data[length:]
+			switch fieldNum {
+			case 1:
+				resp.Message = value
+			case 3:
+				resp.Protocol = value
+			}
+		default:
+			return resp, fmt.Errorf("workload: unsupported wire type %d on field %d", wireType, fieldNum)
+		}
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,37 @@
+// Package workload provides pluggable units of work for the load generator
+// in loadgen: a plain HTTP GET probe (the harness's original behavior) and
+// gRPC unary/server-streaming/bidi-streaming calls against EchoService, each
+// runnable over both HTTP/2 and HTTP/3, so HTTP/3's actual payoff - small-RPC
+// latency and independent streams - can be measured directly instead of only
+// inferring it from a health-check GET.
+package workload
+
+import (
+	"context"
+	"time"
+)
+
+// Metrics is what a single Workload.Do call reports back, in addition to
+// error/success. Streaming workloads populate TimeToFirstByte and
+// InterMessage; the plain HTTP/unary workloads only populate Latency and
+// Messages (always 1).
+type Metrics struct {
+	Latency time.Duration
+
+	// TimeToFirstByte is how long the first response byte/message took to
+	// arrive, zero for workloads with a single round trip.
+	TimeToFirstByte time.Duration
+	// InterMessage is the gap between consecutive response messages in a
+	// stream, one entry per gap (len(messages)-1 entries).
+	InterMessage []time.Duration
+	// Messages is how many response messages were received.
+	Messages int
+}
+
+// Workload is one probeable unit of work: an HTTP GET, a gRPC unary call, or
+// a streaming gRPC call. Implementations are expected to be safe for
+// concurrent use by multiple loadgen.Runner workers, matching how the runner
+// shares one Workload's Do across its goroutine pool.
+type Workload interface {
+	Do(ctx context.Context) (Metrics, error)
+}